@@ -0,0 +1,37 @@
+// Command migrate-media is a one-off migration that moves the legacy
+// poster_base64/photo_base64 columns into the media BlobStore.
+//
+//	go run ./cmd/migrate-media [-drop-columns]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"movie-service/connection"
+	"movie-service/media"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	drop := flag.Bool("drop-columns", false, "drop the base64 columns after a clean migration")
+	flag.Parse()
+
+	_ = godotenv.Load(".env")
+
+	db := connection.Connect()
+	store, err := media.NewStoreFromEnv()
+	if err != nil {
+		log.Fatal("Failed to init media store:", err)
+	}
+
+	stats, err := media.MigrateBase64(context.Background(), media.NewService(db, store), *drop)
+	for _, st := range stats {
+		log.Printf("%s: %d migrated, %d failed", st.Table, st.Migrated, st.Failed)
+	}
+	if err != nil {
+		log.Fatal("Migration failed:", err)
+	}
+}
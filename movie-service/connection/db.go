@@ -28,7 +28,9 @@ func Connect() *gorm.DB {
 		log.Fatal("Failed to connect DB:", err)
 	}
 
-	db.AutoMigrate(&models.Movie{}, &models.Genre{}, &models.Actor{})
+	db.AutoMigrate(&models.Movie{}, &models.Genre{}, &models.Actor{}, &models.Media{}, &models.MediaVariant{},
+		&models.Review{}, &models.ReviewVote{}, &models.MovieRating{},
+		&models.MovieActivity{}, &models.TrendingScore{}, &models.MovieNeighbor{}, &models.MovieCooccurrence{})
 
 	DB = db
 	return db
@@ -1,9 +1,12 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 
+	"movie-service/events"
+	"movie-service/media"
 	"movie-service/models"
 
 	"github.com/gin-gonic/gin"
@@ -11,17 +14,19 @@ import (
 )
 
 type ActorController struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Media  *media.Service
+	Events *events.Bus
 }
 
 type createActorRequest struct {
-	Name        string  `json:"name" binding:"required"`
-	PhotoBase64 *string `json:"photo_base64"` // optional
+	Name         string `json:"name" binding:"required"`
+	PhotoMediaID *uint  `json:"photo_media_id"` // optional, from POST /media
 }
 
 type updateActorRequest struct {
-	Name        *string `json:"name"`
-	PhotoBase64 *string `json:"photo_base64"`
+	Name         *string `json:"name"`
+	PhotoMediaID *uint   `json:"photo_media_id"`
 }
 
 // POST /actors (auth required)
@@ -32,22 +37,28 @@ func (ac *ActorController) CreateActor(c *gin.Context) {
 		return
 	}
 
-	actor := models.Actor{
-		Name: req.Name,
+	if req.PhotoMediaID != nil {
+		if _, err := ac.Media.Find(c.Request.Context(), *req.PhotoMediaID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "photo media not found"})
+			return
+		}
 	}
-	if req.PhotoBase64 != nil {
-		actor.PhotoBase64 = *req.PhotoBase64
+
+	actor := models.Actor{
+		Name:         req.Name,
+		PhotoMediaID: req.PhotoMediaID,
 	}
 
 	if err := ac.DB.Create(&actor).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create actor"})
 		return
 	}
+	ac.Events.Publish(c.Request.Context(), events.Event{Type: events.ActorSaved, ID: actor.ID})
 
 	c.JSON(http.StatusCreated, gin.H{
-		"id":           actor.ID,
-		"name":         actor.Name,
-		"photo_base64": actor.PhotoBase64,
+		"id":        actor.ID,
+		"name":      actor.Name,
+		"photo_url": media.URL(actor.PhotoMediaID),
 	})
 }
 
@@ -58,6 +69,9 @@ func (ac *ActorController) ListActors(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list actors"})
 		return
 	}
+	for i := range actors {
+		actors[i].PhotoURL = media.URL(actors[i].PhotoMediaID)
+	}
 	c.JSON(http.StatusOK, actors)
 }
 
@@ -78,6 +92,7 @@ func (ac *ActorController) GetActor(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query actor"})
 		return
 	}
+	actor.PhotoURL = media.URL(actor.PhotoMediaID)
 	c.JSON(http.StatusOK, actor)
 }
 
@@ -109,14 +124,31 @@ func (ac *ActorController) UpdateActor(c *gin.Context) {
 	if req.Name != nil {
 		actor.Name = *req.Name
 	}
-	if req.PhotoBase64 != nil {
-		actor.PhotoBase64 = *req.PhotoBase64
+	var oldPhoto *uint
+	photoChanged := req.PhotoMediaID != nil && (actor.PhotoMediaID == nil || *actor.PhotoMediaID != *req.PhotoMediaID)
+	if photoChanged {
+		if _, err := ac.Media.Find(c.Request.Context(), *req.PhotoMediaID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "photo media not found"})
+			return
+		}
+		oldPhoto = actor.PhotoMediaID
+		actor.PhotoMediaID = req.PhotoMediaID
 	}
 
 	if err := ac.DB.Save(&actor).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update actor"})
 		return
 	}
+	if photoChanged {
+		if err := ac.Media.EnsureVariants(c.Request.Context(), *actor.PhotoMediaID); err != nil {
+			log.Printf("actor %d: failed to generate photo variants: %v", actor.ID, err)
+		}
+	}
+	if err := ac.Media.Delete(c.Request.Context(), oldPhoto); err != nil {
+		log.Printf("actor %d: failed to delete old photo: %v", actor.ID, err)
+	}
+	ac.Events.Publish(c.Request.Context(), events.Event{Type: events.ActorSaved, ID: actor.ID})
+	actor.PhotoURL = media.URL(actor.PhotoMediaID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "actor updated", "actor": actor})
 }
@@ -130,10 +162,76 @@ func (ac *ActorController) DeleteActor(c *gin.Context) {
 		return
 	}
 
-	if err := ac.DB.Delete(&models.Actor{}, uint(id64)).Error; err != nil {
+	var actor models.Actor
+	if err := ac.DB.First(&actor, uint(id64)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "actor not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query actor"})
+		return
+	}
+
+	// remember the linked movies, the join rows are gone after Clear
+	var movieIDs []uint
+	if err := ac.DB.Table("movie_actors").Where("actor_id = ?", actor.ID).Pluck("movie_id", &movieIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query actor movies"})
+		return
+	}
+	if err := ac.DB.Model(&actor).Association("Movies").Clear(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear movies"})
+		return
+	}
+
+	if err := ac.DB.Delete(&actor).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete actor"})
 		return
 	}
+	if err := ac.Media.Delete(c.Request.Context(), actor.PhotoMediaID); err != nil {
+		log.Printf("actor %d: failed to delete photo: %v", actor.ID, err)
+	}
+	ac.Events.Publish(c.Request.Context(), events.Event{Type: events.ActorDeleted, ID: actor.ID, MovieIDs: movieIDs})
 
 	c.JSON(http.StatusOK, gin.H{"message": "actor deleted"})
 }
+
+// UploadPhoto - POST /actors/:id/photo (auth required, multipart field "file")
+func (ac *ActorController) UploadPhoto(c *gin.Context) {
+	idParam := c.Param("id")
+	id64, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var actor models.Actor
+	if err := ac.DB.First(&actor, uint(id64)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "actor not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query actor"})
+		return
+	}
+
+	m := saveUpload(c, ac.Media)
+	if m == nil {
+		return
+	}
+
+	oldPhoto := actor.PhotoMediaID
+	if err := ac.DB.Model(&actor).Update("photo_media_id", m.ID).Error; err != nil {
+		_ = ac.Media.Delete(c.Request.Context(), &m.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update actor"})
+		return
+	}
+	if err := ac.Media.Delete(c.Request.Context(), oldPhoto); err != nil {
+		log.Printf("actor %d: failed to delete old photo: %v", actor.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "photo updated",
+		"id":        actor.ID,
+		"photo_url": media.URL(&m.ID),
+	})
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"movie-service/media"
+	"movie-service/models"
+	"movie-service/recommend"
+	"movie-service/userclient"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"movie-rest/pkg/auth"
+)
+
+// how many personal picks are ranked per request; pages are cut from these
+const maxForYouPicks = 200
+
+type ForYouController struct {
+	DB      *gorm.DB
+	Users   *userclient.Client
+	Builder *recommend.Builder
+}
+
+// GetForYou - GET /me/recommendations (auth required)
+// Ranked unseen movies from the caller's watchlist and watch history
+// (user-service) and reviews. Paginated with limit (default 20, max 50) and cursor.
+func (fc *ForYouController) GetForYou(c *gin.Context) {
+	userID := auth.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	limit := 20
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		if n > 50 {
+			n = 50
+		}
+		limit = n
+	}
+	offset := 0
+	if raw := c.Query("cursor"); raw != "" {
+		cur, err := decodeCursor(raw)
+		if err != nil || cur.Sort != "for_you" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		if offset, err = strconv.Atoi(cur.Value); err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	var seeds []recommend.Seed
+	seen := map[uint]bool{}
+
+	// user-service being down only costs the watchlist and history signals
+	watchlistCount, historyCount := 0, 0
+	signals, err := fc.Users.Signals(ctx, c.GetHeader("Authorization"))
+	if err != nil {
+		log.Printf("for you: user %d: fetching signals failed: %v", userID, err)
+	} else {
+		for _, w := range signals.Watchlist {
+			seeds = append(seeds, recommend.Seed{MovieID: w.MovieID, Weight: 1, Source: "watchlist"})
+			seen[w.MovieID] = true
+		}
+		// a finished movie is a stronger hint than one that was abandoned
+		for _, h := range signals.History {
+			weight := 0.3
+			if h.Finished {
+				weight = 0.8
+			}
+			seeds = append(seeds, recommend.Seed{MovieID: h.MovieID, Weight: weight, Source: "history"})
+			seen[h.MovieID] = true
+		}
+		watchlistCount, historyCount = len(signals.Watchlist), len(signals.History)
+	}
+
+	var reviews []models.Review
+	if err := fc.DB.Select("movie_id", "score").Where("user_id = ?", userID).Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch reviews"})
+		return
+	}
+	for _, r := range reviews {
+		seeds = append(seeds, recommend.Seed{MovieID: r.MovieID, Weight: recommend.ReviewWeight(r.Score), Source: "review"})
+		seen[r.MovieID] = true
+	}
+
+	picks, err := fc.Builder.ForYou(ctx, seeds, seen, maxForYouPicks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build recommendations"})
+		return
+	}
+
+	if offset > len(picks) {
+		offset = len(picks)
+	}
+	page := picks[offset:]
+	var nextCursor *string
+	if len(page) > limit {
+		page = page[:limit]
+		cur := encodeCursor(listCursor{Sort: "for_you", Order: "desc", Value: strconv.Itoa(offset + limit), ID: page[limit-1].MovieID})
+		nextCursor = &cur
+	}
+
+	ids := make([]uint, 0, len(page))
+	for _, p := range page {
+		ids = append(ids, p.MovieID)
+	}
+	var movies []models.Movie
+	if len(ids) > 0 {
+		if err := fc.DB.Preload("Genres").Preload("Actors").Where("id IN ?", ids).Find(&movies).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch recommendations"})
+			return
+		}
+	}
+	byID := make(map[uint]models.Movie, len(movies))
+	for _, m := range movies {
+		byID[m.ID] = m
+	}
+
+	out := make([]gin.H, 0, len(page))
+	for _, p := range page {
+		m, ok := byID[p.MovieID]
+		if !ok {
+			continue
+		}
+		out = append(out, gin.H{
+			"id":               m.ID,
+			"title":            m.Title,
+			"poster_url":       media.URL(m.PosterMediaID),
+			"duration_minutes": m.DurationMinutes,
+			"synopsis":         m.Synopsis,
+			"release_year":     m.ReleaseYear,
+			"rating":           m.Rating,
+			"is_premium":       m.IsPremium,
+			"genres":           genreIDs(m.Genres),
+			"actors":           actorIDs(m.Actors),
+			"score":            p.Score,
+			"reason":           p.Reason,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        out,
+		"limit":       limit,
+		"next_cursor": nextCursor,
+		"sources": gin.H{
+			"watchlist": watchlistCount,
+			"history":   historyCount,
+			"reviews":   len(reviews),
+		},
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"movie-service/events"
 	"movie-service/models"
 
 	"github.com/gin-gonic/gin"
@@ -11,7 +12,8 @@ import (
 )
 
 type GenreController struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Events *events.Bus
 }
 
 type createGenreRequest struct {
@@ -44,6 +46,7 @@ func (gc *GenreController) CreateGenre(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create genre"})
 		return
 	}
+	gc.Events.Publish(c.Request.Context(), events.Event{Type: events.GenreSaved, ID: genre.ID})
 
 	c.JSON(http.StatusCreated, gin.H{"id": genre.ID, "name": genre.Name})
 }
@@ -111,6 +114,7 @@ func (gc *GenreController) UpdateGenre(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update genre"})
 		return
 	}
+	gc.Events.Publish(c.Request.Context(), events.Event{Type: events.GenreSaved, ID: genre.ID})
 
 	c.JSON(http.StatusOK, gin.H{"message": "genre updated", "genre": genre})
 }
@@ -124,10 +128,24 @@ func (gc *GenreController) DeleteGenre(c *gin.Context) {
 		return
 	}
 
-	if err := gc.DB.Delete(&models.Genre{}, uint(id64)).Error; err != nil {
+	genre := models.Genre{ID: uint(id64)}
+
+	// remember the linked movies, the join rows are gone after Clear
+	var movieIDs []uint
+	if err := gc.DB.Table("movie_genres").Where("genre_id = ?", genre.ID).Pluck("movie_id", &movieIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query genre movies"})
+		return
+	}
+	if err := gc.DB.Model(&genre).Association("Movies").Clear(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear movies"})
+		return
+	}
+
+	if err := gc.DB.Delete(&genre).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete genre"})
 		return
 	}
+	gc.Events.Publish(c.Request.Context(), events.Event{Type: events.GenreDeleted, ID: genre.ID, MovieIDs: movieIDs})
 
 	c.JSON(http.StatusOK, gin.H{"message": "genre deleted"})
 }
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"movie-service/media"
+	"movie-service/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MediaController struct {
+	Media *media.Service
+}
+
+// multipart overhead allowed on top of Media.MaxBytes
+const multipartSlack = 1 << 20
+
+// saveUpload stores the multipart "file" field of the request. On failure it
+// writes the error response itself and returns nil.
+func saveUpload(c *gin.Context, svc *media.Service) *models.Media {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, svc.MaxBytes+multipartSlack)
+
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+			return nil
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "multipart field 'file' is required"})
+		return nil
+	}
+	defer file.Close()
+
+	m, err := svc.Save(c.Request.Context(), file)
+	switch {
+	case err == nil:
+		return m
+	case errors.Is(err, media.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+	case errors.Is(err, media.ErrTooManyPixels):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "image dimensions too large"})
+	case errors.Is(err, media.ErrUnsupportedType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "only jpeg, png, gif and webp images are allowed"})
+	case errors.Is(err, media.ErrEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty file"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store file"})
+	}
+	return nil
+}
+
+// UploadMedia - POST /media (auth required, multipart field "file")
+func (mdc *MediaController) UploadMedia(c *gin.Context) {
+	m := saveUpload(c, mdc.Media)
+	if m == nil {
+		return
+	}
+	widths := make([]int, 0, len(m.Variants))
+	for _, v := range m.Variants {
+		widths = append(widths, v.Width)
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"id":             m.ID,
+		"url":            media.URL(&m.ID),
+		"content_type":   m.ContentType,
+		"size":           m.Size,
+		"width":          m.Width,
+		"height":         m.Height,
+		"variant_widths": widths,
+	})
+}
+
+// GetMedia - GET /media/:id[?w=300] (public)
+// Media rows are immutable (a new upload gets a new id), so responses are
+// cacheable forever and revalidated by checksum. With ?w= the smallest
+// variant at least that wide is served, falling back to the original; that
+// fallback is cached briefly when the original is wider than asked, since
+// the variant may be generated later.
+func (mdc *MediaController) GetMedia(c *gin.Context) {
+	id64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	m, err := mdc.Media.Find(c.Request.Context(), uint(id64))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "media not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query media"})
+		return
+	}
+
+	w, _ := strconv.Atoi(c.Query("w"))
+	v := media.PickVariant(m, w)
+	contentType, size, checksum := m.ContentType, m.Size, m.Checksum
+	if v != nil {
+		contentType, size, checksum = v.ContentType, v.Size, v.Checksum
+	}
+
+	etag := `"` + checksum + `"`
+	c.Header("ETag", etag)
+	if v == nil && w > 0 && w < m.Width {
+		c.Header("Cache-Control", "public, max-age=300")
+	} else {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	rc, err := mdc.Media.Open(c.Request.Context(), m, v)
+	if err != nil {
+		if errors.Is(err, media.ErrBlobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "media not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read media"})
+		return
+	}
+	defer rc.Close()
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatInt(size, 10))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	_, _ = io.Copy(c.Writer, rc)
+}
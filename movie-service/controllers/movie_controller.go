@@ -2,44 +2,48 @@ package controllers
 
 import (
 	"encoding/json"
+	"log"
+	"movie-service/events"
+	"movie-service/media"
 	"movie-service/models"
+	"movie-service/trending"
+	"movie-service/userclient"
 	"net/http"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"movie-rest/pkg/auth"
 	"gorm.io/gorm"
 )
 
 // Request structs
 type createMovieRequest struct {
 	Title           string  `json:"title" binding:"required"`
-	PosterBase64    *string `json:"poster_base64"` // optional
+	PosterMediaID   *uint   `json:"poster_media_id"` // optional, from POST /media
 	DurationMinutes *int    `json:"duration_minutes"`
 	Synopsis        *string `json:"synopsis"`
 	ReleaseYear     *int    `json:"release_year"`
-	Rating          *float32 `json:"rating"`
-	Views           *int64  `json:"views"`
 	Genres          []uint  `json:"genres"` // list of genre IDs
 	Actors          []uint  `json:"actors"` // list of actor IDs
 }
 
 type updateMovieRequest struct {
 	Title           *string  `json:"title"`
-	PosterBase64    *string  `json:"poster_base64"` // if nil => keep old
+	PosterMediaID   *uint    `json:"poster_media_id"` // if nil => keep old
 	DurationMinutes *int     `json:"duration_minutes"`
 	Synopsis        *string  `json:"synopsis"`
 	ReleaseYear     *int     `json:"release_year"`
-	Rating          *float32 `json:"rating"`
-	Views           *int64   `json:"views"`
 	Genres          []uint   `json:"genres"` // full replace if provided (len>0)
 	Actors          []uint   `json:"actors"` // full replace if provided (len>0)
 }
 
 type MovieController struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Media  *media.Service
+	Events *events.Bus
+	Users  *userclient.Client // in_watchlist flags
 }
 
 // helper to map []models.Genre -> []uint
@@ -93,11 +97,16 @@ func (mc *MovieController) CreateMovie(c *gin.Context) {
 		}
 	}
 
-	movie := models.Movie{
-		Title: req.Title,
+	if req.PosterMediaID != nil {
+		if _, err := mc.Media.Find(c.Request.Context(), *req.PosterMediaID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "poster media not found"})
+			return
+		}
 	}
-	if req.PosterBase64 != nil {
-		movie.PosterBase64 = *req.PosterBase64
+
+	movie := models.Movie{
+		Title:         req.Title,
+		PosterMediaID: req.PosterMediaID,
 	}
 	if req.DurationMinutes != nil {
 		movie.DurationMinutes = *req.DurationMinutes
@@ -108,12 +117,6 @@ func (mc *MovieController) CreateMovie(c *gin.Context) {
 	if req.ReleaseYear != nil {
 		movie.ReleaseYear = *req.ReleaseYear
 	}
-	if req.Rating != nil {
-		movie.Rating = *req.Rating
-	}
-	if req.Views != nil {
-		movie.Views = *req.Views
-	}
 
 	if err := mc.DB.Create(&movie).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create movie: " + err.Error()})
@@ -134,6 +137,8 @@ func (mc *MovieController) CreateMovie(c *gin.Context) {
 		}
 	}
 
+	mc.Events.Publish(c.Request.Context(), events.Event{Type: events.MovieSaved, ID: movie.ID})
+
 	// respond with IDs for genres & actors
 	var outGenres []models.Genre
 	var outActors []models.Actor
@@ -143,7 +148,7 @@ func (mc *MovieController) CreateMovie(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"id":               movie.ID,
 		"title":            movie.Title,
-		"poster_base64":    movie.PosterBase64,
+		"poster_url":       media.URL(movie.PosterMediaID),
 		"duration_minutes": movie.DurationMinutes,
 		"synopsis":         movie.Synopsis,
 		"release_year":     movie.ReleaseYear,
@@ -155,72 +160,164 @@ func (mc *MovieController) CreateMovie(c *gin.Context) {
 }
 
 // GetMovies - GET /movies (public)
+// Supports keyset pagination (limit, cursor), sort/order and filters, see movie_query.go
 func (mc *MovieController) GetMovies(c *gin.Context) {
+	q, err := parseMovieListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// ambil satu baris ekstra untuk mengetahui apakah masih ada halaman berikutnya
 	var movies []models.Movie
-	
-	// Membangun query dasar
-	query := mc.DB.Preload("Genres").Preload("Actors")
+	query := q.applyKeyset(q.applyFilters(mc.DB.Model(&models.Movie{})))
+	if err := query.Preload("Genres").Preload("Actors").Limit(q.Limit + 1).Find(&movies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list movies"})
+		return
+	}
 
-	// Cek apakah ada parameter 'search'
-	searchQuery := c.Query("search")
-	if searchQuery != "" {
-		// Menambahkan kondisi WHERE untuk pencarian judul (case-insensitive)
-		query = query.Where("LOWER(title) LIKE ?", "%"+strings.ToLower(searchQuery)+"%")
+	var nextCursor *string
+	if len(movies) > q.Limit {
+		movies = movies[:q.Limit]
+		cur, err := q.nextCursor(mc.DB, movies[len(movies)-1].ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build cursor"})
+			return
+		}
+		nextCursor = &cur
 	}
 
-	if err := query.Find(&movies).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list movies"})
+	posterIDs := make([]uint, 0, len(movies))
+	for _, m := range movies {
+		if m.PosterMediaID != nil {
+			posterIDs = append(posterIDs, *m.PosterMediaID)
+		}
+	}
+	srcsets, err := mc.Media.Srcsets(c.Request.Context(), posterIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query posters"})
 		return
 	}
 
 	out := make([]gin.H, 0, len(movies))
 	for _, m := range movies {
+		srcset := ""
+		if m.PosterMediaID != nil {
+			srcset = srcsets[*m.PosterMediaID]
+		}
 		out = append(out, gin.H{
 			"id":               m.ID,
 			"title":            m.Title,
-			"poster_base64":    m.PosterBase64,
+			"poster_url":       media.URL(m.PosterMediaID),
+			"poster_srcset":    srcset,
 			"duration_minutes": m.DurationMinutes,
 			"synopsis":         m.Synopsis,
 			"release_year":     m.ReleaseYear,
 			"rating":           m.Rating,
 			"views":            m.Views,
+			"is_premium":       m.IsPremium,
 			"genres":           genreIDs(m.Genres),
 			"actors":           actorIDs(m.Actors),
 		})
 	}
-	c.JSON(http.StatusOK, out)
+	flagWatchlist(c, mc.Users, out)
+	c.JSON(http.StatusOK, gin.H{
+		"data":        out,
+		"limit":       q.Limit,
+		"next_cursor": nextCursor,
+	})
 }
 
 // GetTrendingMovies - GET /movies/trending (public)
 func (mc *MovieController) GetTrendingMovies(c *gin.Context) {
-    var movies []models.Movie
-    // Mengambil 10 film, diurutkan berdasarkan rating dari tertinggi ke terendah
-    if err := mc.DB.Preload("Genres").Preload("Actors").Order("rating desc").Limit(10).Find(&movies).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch trending movies"})
-        return
-    }
-
-    out := make([]gin.H, 0, len(movies))
-    for _, m := range movies {
-        out = append(out, gin.H{
-            "id":               m.ID,
-            "title":            m.Title,
-            "poster_base64":    m.PosterBase64,
-            "duration_minutes": m.DurationMinutes,
-            "synopsis":         m.Synopsis,
-            "release_year":     m.ReleaseYear,
-            "rating":           m.Rating,
-            "views":            m.Views,
-            "genres":           genreIDs(m.Genres),
-            "actors":           actorIDs(m.Actors),
-        })
-    }
-    c.JSON(http.StatusOK, out)
+	window := c.DefaultQuery("window", "day")
+	if _, ok := trending.FindWindow(window); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid window, use day, week or month"})
+		return
+	}
+	limit := 10
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		if n > 50 {
+			n = 50
+		}
+		limit = n
+	}
+
+	// rankings are precomputed by trending.Job; here we only read them
+	q := mc.DB.Model(&models.TrendingScore{}).Where("trending_scores.period = ?", window)
+	if raw := c.Query("genre"); raw != "" {
+		genreID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid genre"})
+			return
+		}
+		q = q.Where("trending_scores.movie_id IN (SELECT movie_id FROM movie_genres WHERE genre_id = ?)", genreID)
+	}
+	var scores []models.TrendingScore
+	if err := q.Order("trending_scores.rank").Limit(limit).Find(&scores).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch trending movies"})
+		return
+	}
+
+	ids := make([]uint, 0, len(scores))
+	for _, s := range scores {
+		ids = append(ids, s.MovieID)
+	}
+	var movies []models.Movie
+	if len(ids) > 0 {
+		if err := mc.DB.Preload("Genres").Preload("Actors").Where("id IN ?", ids).Find(&movies).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch trending movies"})
+			return
+		}
+	}
+	byID := make(map[uint]models.Movie, len(movies))
+	for _, m := range movies {
+		byID[m.ID] = m
+	}
+
+	var computedAt *time.Time
+	out := make([]gin.H, 0, len(scores))
+	for _, s := range scores {
+		if computedAt == nil {
+			t := s.ComputedAt
+			computedAt = &t
+		}
+		// a movie deleted since the last recompute is skipped
+		m, ok := byID[s.MovieID]
+		if !ok {
+			continue
+		}
+		out = append(out, gin.H{
+			"id":               m.ID,
+			"title":            m.Title,
+			"poster_url":       media.URL(m.PosterMediaID),
+			"duration_minutes": m.DurationMinutes,
+			"synopsis":         m.Synopsis,
+			"release_year":     m.ReleaseYear,
+			"rating":           m.Rating,
+			"views":            m.Views,
+			"is_premium":       m.IsPremium,
+			"genres":           genreIDs(m.Genres),
+			"actors":           actorIDs(m.Actors),
+			"rank":             s.Rank,
+			"trending_score":   s.Score,
+		})
+	}
+	flagWatchlist(c, mc.Users, out)
+	c.JSON(http.StatusOK, gin.H{
+		"window":      window,
+		"computed_at": computedAt,
+		"data":        out,
+	})
 }
 
 // GetMovieByID - GET /movies/:id (public)
-// movie-service/controllers/movie_controller.go
-
+// Premium movies need a signed-in caller with an active subscription.
 func (mc *MovieController) GetMovieByID(c *gin.Context) {
 	idParam := c.Param("id")
 	id64, err := strconv.ParseUint(idParam, 10, 64)
@@ -244,9 +341,8 @@ func (mc *MovieController) GetMovieByID(c *gin.Context) {
 	// PINDAHKAN LOGIKA PENGECEKAN PREMIUM KE SINI (SEBELUM MENGIRIM JSON)
 	// =================================================================
 	if movie.IsPremium {
-		// Middleware seharusnya sudah menempatkan user_id jika token valid
-		_, exists := c.Get("user_id")
-		if !exists {
+		// auth.Optional menempatkan user_id jika token valid
+		if auth.UserID(c) == 0 {
 			c.JSON(http.StatusForbidden, gin.H{
 				"error":   "subscription_required",
 				"message": "This is premium content. Please subscribe.",
@@ -258,8 +354,8 @@ func (mc *MovieController) GetMovieByID(c *gin.Context) {
 		userServiceURL := os.Getenv("USER_SERVICE_URL")
 		req, _ := http.NewRequest("GET", userServiceURL+"/profile", nil)
 
-		auth := c.GetHeader("Authorization")
-		if auth == "" {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
 			c.JSON(http.StatusForbidden, gin.H{
 				"error":   "subscription_required",
 				"message": "Please login & subscribe to access premium content.",
@@ -267,10 +363,18 @@ func (mc *MovieController) GetMovieByID(c *gin.Context) {
 			return // <-- Hentikan eksekusi
 		}
 
-		req.Header.Set("Authorization", auth)
+		req.Header.Set("Authorization", authHeader)
 		client := &http.Client{Timeout: time.Second * 5}
 		resp, err := client.Do(req)
-		if err != nil || resp.StatusCode != 200 {
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "subscription_required",
+				"message": "Cannot verify subscription",
+			})
+			return // <-- Hentikan eksekusi
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
 			c.JSON(http.StatusForbidden, gin.H{
 				"error":   "subscription_required",
 				"message": "Cannot verify subscription",
@@ -310,12 +414,23 @@ func (mc *MovieController) GetMovieByID(c *gin.Context) {
 		}
 	}
 
+	srcset := ""
+	if movie.PosterMediaID != nil {
+		srcsets, err := mc.Media.Srcsets(c.Request.Context(), []uint{*movie.PosterMediaID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query poster"})
+			return
+		}
+		srcset = srcsets[*movie.PosterMediaID]
+	}
+
 	// Jika semua pengecekan premium lolos (atau jika film tidak premium),
 	// baru kirimkan detail filmnya.
-	c.JSON(http.StatusOK, gin.H{
+	out := gin.H{
 		"id":               movie.ID,
 		"title":            movie.Title,
-		"poster_base_64":   movie.PosterBase64,
+		"poster_url":       media.URL(movie.PosterMediaID),
+		"poster_srcset":    srcset,
 		"duration_minutes": movie.DurationMinutes,
 		"synopsis":         movie.Synopsis,
 		"release_year":     movie.ReleaseYear,
@@ -323,76 +438,119 @@ func (mc *MovieController) GetMovieByID(c *gin.Context) {
 		"views":            movie.Views,
 		"genres":           genreIDs(movie.Genres),
 		"actors":           actorIDs(movie.Actors),
-	})
+	}
+	flagWatchlist(c, mc.Users, []gin.H{out})
+	c.JSON(http.StatusOK, out)
 }
 
+// GetMovieRecommendations - GET /movies/:id/recommendations (public)
+// Reads the neighbor list precomputed by recommend.Builder, best match first.
+// Paginated with limit (default 10, max 50) and cursor.
 func (mc *MovieController) GetMovieRecommendations(c *gin.Context) {
-    // 1. Dapatkan ID film utama dari parameter URL
-    idParam := c.Param("id")
-    id64, err := strconv.ParseUint(idParam, 10, 64)
-    if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid movie id"})
-        return
-    }
-    movieID := uint(id64)
-
-    // 2. Ambil data film utama beserta genrenya
-    var primaryMovie models.Movie
-    if err := mc.DB.Preload("Genres").First(&primaryMovie, movieID).Error; err != nil {
-        if err == gorm.ErrRecordNotFound {
-            c.JSON(http.StatusNotFound, gin.H{"error": "primary movie not found"})
-            return
-        }
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query primary movie"})
-        return
-    }
-
-    if len(primaryMovie.Genres) == 0 {
-        c.JSON(http.StatusOK, []gin.H{})
-        return
-    }
-
-    // 3. Ekstrak semua ID genre dari film utama
-    targetGenreIDs := make([]uint, len(primaryMovie.Genres))
-    for i, genre := range primaryMovie.Genres {
-        targetGenreIDs[i] = genre.ID
-    }
-
-    // 4. Cari 10 film lain yang memiliki salah satu dari genre tersebut
-    var recommendations []models.Movie
-    err = mc.DB.
-        Joins("JOIN movie_genres ON movies.id = movie_genres.movie_id").
-        Where("movie_genres.genre_id IN ?", targetGenreIDs).
-        Where("movies.id != ?", movieID).
-        Distinct().
-        Limit(10).
-        Preload("Genres").
-        Preload("Actors").
-        Find(&recommendations).Error
-
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch recommendations"})
-        return
-    }
-
-    // 5. Format outputnya
-    out := make([]gin.H, 0, len(recommendations))
-    for _, m := range recommendations {
-        out = append(out, gin.H{
-            "id":               m.ID,
-            "title":            m.Title,
-            "poster_base64":    m.PosterBase64,
-            "duration_minutes": m.DurationMinutes,
-            "synopsis":         m.Synopsis,
-            "release_year":     m.ReleaseYear,
-            "rating":           m.Rating,
-            "genres":           genreIDs(m.Genres), 
-            "actors":           actorIDs(m.Actors), 
-        })
-    }
-
-    c.JSON(http.StatusOK, out)
+	idParam := c.Param("id")
+	id64, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid movie id"})
+		return
+	}
+	movieID := uint(id64)
+
+	limit := 10
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		if n > 50 {
+			n = 50
+		}
+		limit = n
+	}
+	// ranks are unique per movie, so the rank of the last row is a complete key
+	afterRank := 0
+	if raw := c.Query("cursor"); raw != "" {
+		cur, err := decodeCursor(raw)
+		if err != nil || cur.Sort != "rank" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		if afterRank, err = strconv.Atoi(cur.Value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+
+	var primaryMovie models.Movie
+	if err := mc.DB.Select("id").First(&primaryMovie, movieID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "primary movie not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query primary movie"})
+		return
+	}
+
+	var neighbors []models.MovieNeighbor
+	if err := mc.DB.Where("movie_id = ? AND rank > ?", movieID, afterRank).
+		Order("rank").Limit(limit + 1).Find(&neighbors).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch recommendations"})
+		return
+	}
+	var nextCursor *string
+	if len(neighbors) > limit {
+		neighbors = neighbors[:limit]
+		last := neighbors[len(neighbors)-1]
+		cur := encodeCursor(listCursor{Sort: "rank", Order: "asc", Value: strconv.Itoa(last.Rank), ID: last.NeighborID})
+		nextCursor = &cur
+	}
+
+	ids := make([]uint, 0, len(neighbors))
+	for _, n := range neighbors {
+		ids = append(ids, n.NeighborID)
+	}
+	var recommendations []models.Movie
+	if len(ids) > 0 {
+		if err := mc.DB.Preload("Genres").Preload("Actors").Where("id IN ?", ids).Find(&recommendations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch recommendations"})
+			return
+		}
+	}
+	byID := make(map[uint]models.Movie, len(recommendations))
+	for _, m := range recommendations {
+		byID[m.ID] = m
+	}
+
+	out := make([]gin.H, 0, len(neighbors))
+	for _, n := range neighbors {
+		m, ok := byID[n.NeighborID]
+		if !ok {
+			continue
+		}
+		out = append(out, gin.H{
+			"id":               m.ID,
+			"title":            m.Title,
+			"poster_url":       media.URL(m.PosterMediaID),
+			"duration_minutes": m.DurationMinutes,
+			"synopsis":         m.Synopsis,
+			"release_year":     m.ReleaseYear,
+			"rating":           m.Rating,
+			"is_premium":       m.IsPremium,
+			"genres":           genreIDs(m.Genres),
+			"actors":           actorIDs(m.Actors),
+			"score":            n.Score,
+			"reason":           n.Reason,
+		})
+	}
+	flagWatchlist(c, mc.Users, out)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        out,
+		"limit":       limit,
+		"next_cursor": nextCursor,
+	})
 }
+
 // UpdateMovie - PATCH /movies/:id (auth required)
 func (mc *MovieController) UpdateMovie(c *gin.Context) {
 	idParam := c.Param("id")
@@ -423,9 +581,15 @@ func (mc *MovieController) UpdateMovie(c *gin.Context) {
 	if req.Title != nil {
 		movie.Title = *req.Title
 	}
-	if req.PosterBase64 != nil {
-		// if provided (even empty string), replace
-		movie.PosterBase64 = *req.PosterBase64
+	var oldPoster *uint
+	posterChanged := req.PosterMediaID != nil && (movie.PosterMediaID == nil || *movie.PosterMediaID != *req.PosterMediaID)
+	if posterChanged {
+		if _, err := mc.Media.Find(c.Request.Context(), *req.PosterMediaID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "poster media not found"})
+			return
+		}
+		oldPoster = movie.PosterMediaID
+		movie.PosterMediaID = req.PosterMediaID
 	}
 	if req.DurationMinutes != nil {
 		movie.DurationMinutes = *req.DurationMinutes
@@ -436,12 +600,6 @@ func (mc *MovieController) UpdateMovie(c *gin.Context) {
 	if req.ReleaseYear != nil {
 		movie.ReleaseYear = *req.ReleaseYear
 	}
-	if req.Rating != nil {
-		movie.Rating = *req.Rating
-	}
-	if req.Views != nil {
-		movie.Views = *req.Views
-	}
 
 	// handle genres replacement
 	if req.Genres != nil {
@@ -492,11 +650,22 @@ func (mc *MovieController) UpdateMovie(c *gin.Context) {
 		}
 	}
 
-	// Save movie
-	if err := mc.DB.Save(&movie).Error; err != nil {
+	// Save movie; rating is owned by the review aggregate and views by the
+	// view counter, never overwrite them here
+	if err := mc.DB.Omit("rating", "views").Save(&movie).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update movie"})
 		return
 	}
+	if posterChanged {
+		// gambar baru: pastikan semua ukuran varian tersedia
+		if err := mc.Media.EnsureVariants(c.Request.Context(), *movie.PosterMediaID); err != nil {
+			log.Printf("movie %d: failed to generate poster variants: %v", movie.ID, err)
+		}
+	}
+	if err := mc.Media.Delete(c.Request.Context(), oldPoster); err != nil {
+		log.Printf("movie %d: failed to delete old poster: %v", movie.ID, err)
+	}
+	mc.Events.Publish(c.Request.Context(), events.Event{Type: events.MovieSaved, ID: movie.ID})
 
 	// reload associations
 	var outGenres []models.Genre
@@ -508,7 +677,7 @@ func (mc *MovieController) UpdateMovie(c *gin.Context) {
 		"message":          "movie updated",
 		"id":               movie.ID,
 		"title":            movie.Title,
-		"poster_base64":    movie.PosterBase64,
+		"poster_url":       media.URL(movie.PosterMediaID),
 		"duration_minutes": movie.DurationMinutes,
 		"synopsis":         movie.Synopsis,
 		"release_year":     movie.ReleaseYear,
@@ -549,10 +718,74 @@ func (mc *MovieController) DeleteMovie(c *gin.Context) {
 		return
 	}
 
-	if err := mc.DB.Delete(&movie).Error; err != nil {
+	err = mc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("review_id IN (SELECT id FROM reviews WHERE movie_id = ?)", movie.ID).
+			Delete(&models.ReviewVote{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("movie_id = ?", movie.ID).Delete(&models.Review{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("movie_id = ?", movie.ID).Delete(&models.MovieRating{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("movie_id = ?", movie.ID).Delete(&models.MovieActivity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("movie_id = ?", movie.ID).Delete(&models.TrendingScore{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&movie).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete movie"})
 		return
 	}
+	if err := mc.Media.Delete(c.Request.Context(), movie.PosterMediaID); err != nil {
+		log.Printf("movie %d: failed to delete poster: %v", movie.ID, err)
+	}
+	mc.Events.Publish(c.Request.Context(), events.Event{Type: events.MovieDeleted, ID: movie.ID})
 
 	c.JSON(http.StatusOK, gin.H{"message": "movie deleted"})
 }
+
+// UploadPoster - POST /movies/:id/poster (auth required, multipart field "file")
+func (mc *MovieController) UploadPoster(c *gin.Context) {
+	idParam := c.Param("id")
+	id64, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var movie models.Movie
+	if err := mc.DB.First(&movie, uint(id64)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "movie not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query movie"})
+		return
+	}
+
+	m := saveUpload(c, mc.Media)
+	if m == nil {
+		return
+	}
+
+	oldPoster := movie.PosterMediaID
+	if err := mc.DB.Model(&movie).Update("poster_media_id", m.ID).Error; err != nil {
+		_ = mc.Media.Delete(c.Request.Context(), &m.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update movie"})
+		return
+	}
+	if err := mc.Media.Delete(c.Request.Context(), oldPoster); err != nil {
+		log.Printf("movie %d: failed to delete old poster: %v", movie.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "poster updated",
+		"id":         movie.ID,
+		"poster_url": media.URL(&m.ID),
+	})
+}
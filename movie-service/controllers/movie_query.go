@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMovieLimit = 20
+	maxMovieLimit     = 100
+)
+
+// sortable columns for GET /movies; the key is the value accepted in ?sort=
+var movieSortColumns = map[string]string{
+	"rating":       "movies.rating",
+	"views":        "movies.views",
+	"release_year": "movies.release_year",
+	"created_at":   "movies.created_at",
+}
+
+// listCursor is the decoded form of the opaque cursor / next_cursor string
+// of keyset-paginated lists. Value holds the sort column of the last row as
+// Postgres text so keyset comparison is exact: rating is a real column, and
+// real::text prints the shortest digits that read back as the same value.
+type listCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func encodeCursor(cur listCursor) string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	var cur listCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, errors.New("invalid cursor")
+	}
+	if err := json.Unmarshal(b, &cur); err != nil || cur.ID == 0 {
+		return cur, errors.New("invalid cursor")
+	}
+	return cur, nil
+}
+
+// movieListQuery holds the parsed query string of GET /movies
+type movieListQuery struct {
+	Limit  int
+	Sort   string
+	Order  string
+	Cursor *listCursor
+
+	Search      string
+	IDs         []uint
+	GenreIDs    []uint
+	ActorIDs    []uint
+	YearMin     *int
+	YearMax     *int
+	RatingMin   *float64
+	RatingMax   *float64
+	DurationMin *int
+	DurationMax *int
+	IsPremium   *bool
+}
+
+// parseUintList parses "1,2,3" into []uint
+func parseUintList(raw string) ([]uint, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	parts := strings.Split(raw, ",")
+	out := make([]uint, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, uint(v))
+	}
+	return out, nil
+}
+
+func optionalInt(c *gin.Context, key string) (*int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", key)
+	}
+	return &v, nil
+}
+
+func optionalFloat(c *gin.Context, key string) (*float64, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", key)
+	}
+	return &v, nil
+}
+
+func parseMovieListQuery(c *gin.Context) (*movieListQuery, error) {
+	q := &movieListQuery{
+		Limit:  defaultMovieLimit,
+		Sort:   "created_at",
+		Order:  "desc",
+		Search: c.Query("search"),
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return nil, errors.New("invalid limit")
+		}
+		if n > maxMovieLimit {
+			n = maxMovieLimit
+		}
+		q.Limit = n
+	}
+
+	if raw := c.Query("sort"); raw != "" {
+		if _, ok := movieSortColumns[raw]; !ok {
+			return nil, errors.New("invalid sort, use rating, views, release_year or created_at")
+		}
+		q.Sort = raw
+	}
+	if raw := strings.ToLower(c.Query("order")); raw != "" {
+		if raw != "asc" && raw != "desc" {
+			return nil, errors.New("invalid order, use asc or desc")
+		}
+		q.Order = raw
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cur, err := decodeCursor(raw)
+		if err != nil {
+			return nil, err
+		}
+		// a cursor is only valid for the ordering it was issued for
+		if cur.Sort != q.Sort || cur.Order != q.Order {
+			return nil, errors.New("cursor does not match sort/order")
+		}
+		q.Cursor = &cur
+	}
+
+	var err error
+	if q.IDs, err = parseUintList(c.Query("ids")); err != nil {
+		return nil, errors.New("invalid ids")
+	}
+	if len(q.IDs) > maxMovieLimit {
+		return nil, fmt.Errorf("at most %d ids per request", maxMovieLimit)
+	}
+	if q.GenreIDs, err = parseUintList(c.Query("genres")); err != nil {
+		return nil, errors.New("invalid genres")
+	}
+	if q.ActorIDs, err = parseUintList(c.Query("actors")); err != nil {
+		return nil, errors.New("invalid actors")
+	}
+	if q.YearMin, err = optionalInt(c, "year_min"); err != nil {
+		return nil, err
+	}
+	if q.YearMax, err = optionalInt(c, "year_max"); err != nil {
+		return nil, err
+	}
+	if q.RatingMin, err = optionalFloat(c, "rating_min"); err != nil {
+		return nil, err
+	}
+	if q.RatingMax, err = optionalFloat(c, "rating_max"); err != nil {
+		return nil, err
+	}
+	if q.DurationMin, err = optionalInt(c, "duration_min"); err != nil {
+		return nil, err
+	}
+	if q.DurationMax, err = optionalInt(c, "duration_max"); err != nil {
+		return nil, err
+	}
+	if raw := c.Query("is_premium"); raw != "" {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("invalid is_premium")
+		}
+		q.IsPremium = &b
+	}
+
+	return q, nil
+}
+
+// applyFilters adds the WHERE clauses of q to db. Genre and actor filters use
+// subqueries on the join tables so combining them never multiplies rows.
+func (q *movieListQuery) applyFilters(db *gorm.DB) *gorm.DB {
+	if q.Search != "" {
+		db = db.Where("LOWER(movies.title) LIKE ?", "%"+strings.ToLower(q.Search)+"%")
+	}
+	if len(q.IDs) > 0 {
+		db = db.Where("movies.id IN ?", q.IDs)
+	}
+	if len(q.GenreIDs) > 0 {
+		db = db.Where("movies.id IN (SELECT movie_id FROM movie_genres WHERE genre_id IN ?)", q.GenreIDs)
+	}
+	if len(q.ActorIDs) > 0 {
+		db = db.Where("movies.id IN (SELECT movie_id FROM movie_actors WHERE actor_id IN ?)", q.ActorIDs)
+	}
+	if q.YearMin != nil {
+		db = db.Where("movies.release_year >= ?", *q.YearMin)
+	}
+	if q.YearMax != nil {
+		db = db.Where("movies.release_year <= ?", *q.YearMax)
+	}
+	if q.RatingMin != nil {
+		db = db.Where("movies.rating >= ?", *q.RatingMin)
+	}
+	if q.RatingMax != nil {
+		db = db.Where("movies.rating <= ?", *q.RatingMax)
+	}
+	if q.DurationMin != nil {
+		db = db.Where("movies.duration_minutes >= ?", *q.DurationMin)
+	}
+	if q.DurationMax != nil {
+		db = db.Where("movies.duration_minutes <= ?", *q.DurationMax)
+	}
+	if q.IsPremium != nil {
+		db = db.Where("movies.is_premium = ?", *q.IsPremium)
+	}
+	return db
+}
+
+// applyKeyset adds the cursor condition and ordering. movies.id is the
+// tie-breaker so the ordering is total.
+func (q *movieListQuery) applyKeyset(db *gorm.DB) *gorm.DB {
+	col := movieSortColumns[q.Sort]
+	if q.Cursor != nil {
+		cmp := "<"
+		if q.Order == "asc" {
+			cmp = ">"
+		}
+		db = db.Where(fmt.Sprintf("(%s, movies.id) %s (CAST(? AS %s), ?)", col, cmp, movieSortCast(q.Sort)),
+			q.Cursor.Value, q.Cursor.ID)
+	}
+	return db.Order(fmt.Sprintf("%s %s, movies.id %s", col, q.Order, q.Order))
+}
+
+func movieSortCast(sort string) string {
+	switch sort {
+	case "created_at":
+		return "timestamptz"
+	case "rating":
+		return "real"
+	default:
+		return "bigint"
+	}
+}
+
+// nextCursor builds the cursor pointing after the movie with the given id
+func (q *movieListQuery) nextCursor(db *gorm.DB, lastID uint) (string, error) {
+	var value string
+	col := movieSortColumns[q.Sort]
+	if err := db.Raw(fmt.Sprintf("SELECT %s::text FROM movies WHERE movies.id = ?", col), lastID).
+		Scan(&value).Error; err != nil {
+		return "", err
+	}
+	return encodeCursor(listCursor{Sort: q.Sort, Order: q.Order, Value: value, ID: lastID}), nil
+}
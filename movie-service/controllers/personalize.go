@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"log"
+
+	"movie-service/userclient"
+
+	"github.com/gin-gonic/gin"
+	"movie-rest/pkg/auth"
+)
+
+// flagWatchlist adds in_watchlist to every movie in out when the caller is
+// signed in. The watchlist is the caller's default list, asked from
+// user-service with their token. Anonymous responses stay as they are, and
+// a failed lookup only costs the flags.
+func flagWatchlist(c *gin.Context, users *userclient.Client, out []gin.H) {
+	userID := auth.UserID(c)
+	if userID == 0 || len(out) == 0 || users == nil {
+		return
+	}
+
+	ids := make([]uint, 0, len(out))
+	for _, m := range out {
+		if id, ok := m["id"].(uint); ok {
+			ids = append(ids, id)
+		}
+	}
+	movieIDs, err := users.WatchlistIDs(c.Request.Context(), c.GetHeader("Authorization"), ids)
+	if err != nil {
+		log.Printf("watchlist flags: user %d: %v", userID, err)
+		return
+	}
+	saved := map[uint]bool{}
+	for _, id := range movieIDs {
+		saved[id] = true
+	}
+
+	for _, m := range out {
+		id, _ := m["id"].(uint)
+		m["in_watchlist"] = saved[id]
+	}
+}
@@ -0,0 +1,433 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"movie-service/events"
+	"movie-service/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"movie-rest/pkg/auth"
+)
+
+type ReviewController struct {
+	DB     *gorm.DB
+	Events *events.Bus
+}
+
+type createReviewRequest struct {
+	Score int    `json:"score" binding:"required,min=1,max=10"`
+	Body  string `json:"body" binding:"max=5000"`
+}
+
+type updateReviewRequest struct {
+	Score *int    `json:"score" binding:"omitempty,min=1,max=10"`
+	Body  *string `json:"body" binding:"omitempty,max=5000"`
+}
+
+var (
+	errMovieNotFound  = errors.New("movie not found")
+	errReviewNotFound = errors.New("review not found")
+	errReviewExists   = errors.New("you already reviewed this movie, use PATCH to edit it")
+	errNotReviewOwner = errors.New("you can only change your own review")
+	errOwnReviewVote  = errors.New("you cannot vote on your own review")
+)
+
+func reviewErrorStatus(err error) int {
+	switch err {
+	case errMovieNotFound, errReviewNotFound:
+		return http.StatusNotFound
+	case errReviewExists:
+		return http.StatusConflict
+	case errNotReviewOwner:
+		return http.StatusForbidden
+	case errOwnReviewVote:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// lockMovie takes a row lock on the movie so concurrent review writes
+// recompute the aggregate one after another
+func lockMovie(tx *gorm.DB, movieID uint) error {
+	var movie models.Movie
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&movie, movieID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errMovieNotFound
+	}
+	return err
+}
+
+// recomputeMovieRating rebuilds the aggregate of a movie from its reviews
+// and copies the average into movies.rating
+func recomputeMovieRating(tx *gorm.DB, movieID uint) error {
+	var rows []struct {
+		Score int
+		N     int64
+	}
+	if err := tx.Model(&models.Review{}).Select("score, COUNT(*) AS n").
+		Where("movie_id = ?", movieID).Group("score").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	agg := models.MovieRating{MovieID: movieID}
+	var sum int64
+	for _, r := range rows {
+		if r.Score < 1 || r.Score > 10 {
+			continue
+		}
+		agg.Histogram[r.Score-1] = r.N
+		agg.Count += r.N
+		sum += int64(r.Score) * r.N
+	}
+	if agg.Count > 0 {
+		agg.Average = math.Round(float64(sum)/float64(agg.Count)*100) / 100
+	}
+
+	if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&agg).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Movie{}).Where("id = ?", movieID).Update("rating", agg.Average).Error
+}
+
+func parseReviewParams(c *gin.Context) (movieID, reviewID uint, ok bool) {
+	m64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid movie id"})
+		return 0, 0, false
+	}
+	if c.Param("reviewId") == "" {
+		return uint(m64), 0, true
+	}
+	r64, err := strconv.ParseUint(c.Param("reviewId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid review id"})
+		return 0, 0, false
+	}
+	return uint(m64), uint(r64), true
+}
+
+// findReview loads a review of the given movie inside tx
+func findReview(tx *gorm.DB, movieID, reviewID uint) (*models.Review, error) {
+	var review models.Review
+	err := tx.Where("id = ? AND movie_id = ?", reviewID, movieID).First(&review).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// CreateReview - POST /movies/:id/reviews (auth required)
+func (rc *ReviewController) CreateReview(c *gin.Context) {
+	userID := auth.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	movieID, _, ok := parseReviewParams(c)
+	if !ok {
+		return
+	}
+
+	var req createReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review := models.Review{
+		MovieID: movieID,
+		UserID:  userID,
+		Score:   req.Score,
+		Body:    strings.TrimSpace(req.Body),
+	}
+	err := rc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockMovie(tx, movieID); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.Review{}).Where("movie_id = ? AND user_id = ?", movieID, userID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errReviewExists
+		}
+		if err := tx.Create(&review).Error; err != nil {
+			return err
+		}
+		return recomputeMovieRating(tx, movieID)
+	})
+	if err != nil {
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	rc.Events.Publish(c.Request.Context(), events.Event{Type: events.MovieSaved, ID: movieID, RatingOnly: true})
+
+	c.JSON(http.StatusCreated, review)
+}
+
+// UpdateReview - PATCH /movies/:id/reviews/:reviewId (auth required, own review only)
+func (rc *ReviewController) UpdateReview(c *gin.Context) {
+	userID := auth.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	movieID, reviewID, ok := parseReviewParams(c)
+	if !ok {
+		return
+	}
+
+	var req updateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var review *models.Review
+	err := rc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockMovie(tx, movieID); err != nil {
+			return err
+		}
+		var err error
+		if review, err = findReview(tx, movieID, reviewID); err != nil {
+			return err
+		}
+		if review.UserID != userID {
+			return errNotReviewOwner
+		}
+		if req.Score != nil {
+			review.Score = *req.Score
+		}
+		if req.Body != nil {
+			review.Body = strings.TrimSpace(*req.Body)
+		}
+		if err := tx.Save(review).Error; err != nil {
+			return err
+		}
+		return recomputeMovieRating(tx, movieID)
+	})
+	if err != nil {
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	rc.Events.Publish(c.Request.Context(), events.Event{Type: events.MovieSaved, ID: movieID, RatingOnly: true})
+
+	c.JSON(http.StatusOK, gin.H{"message": "review updated", "review": review})
+}
+
+// DeleteReview - DELETE /movies/:id/reviews/:reviewId (auth required, own review only)
+func (rc *ReviewController) DeleteReview(c *gin.Context) {
+	userID := auth.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	movieID, reviewID, ok := parseReviewParams(c)
+	if !ok {
+		return
+	}
+
+	err := rc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockMovie(tx, movieID); err != nil {
+			return err
+		}
+		review, err := findReview(tx, movieID, reviewID)
+		if err != nil {
+			return err
+		}
+		if review.UserID != userID {
+			return errNotReviewOwner
+		}
+		if err := tx.Where("review_id = ?", review.ID).Delete(&models.ReviewVote{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(review).Error; err != nil {
+			return err
+		}
+		return recomputeMovieRating(tx, movieID)
+	})
+	if err != nil {
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	rc.Events.Publish(c.Request.Context(), events.Event{Type: events.MovieSaved, ID: movieID, RatingOnly: true})
+
+	c.JSON(http.StatusOK, gin.H{"message": "review deleted"})
+}
+
+// MarkHelpful - POST /movies/:id/reviews/:reviewId/helpful (auth required)
+// Voting twice is a no-op.
+func (rc *ReviewController) MarkHelpful(c *gin.Context) {
+	rc.vote(c, true)
+}
+
+// UnmarkHelpful - DELETE /movies/:id/reviews/:reviewId/helpful (auth required)
+func (rc *ReviewController) UnmarkHelpful(c *gin.Context) {
+	rc.vote(c, false)
+}
+
+func (rc *ReviewController) vote(c *gin.Context, helpful bool) {
+	userID := auth.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	movieID, reviewID, ok := parseReviewParams(c)
+	if !ok {
+		return
+	}
+
+	var review *models.Review
+	err := rc.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if review, err = findReview(tx, movieID, reviewID); err != nil {
+			return err
+		}
+		if review.UserID == userID {
+			return errOwnReviewVote
+		}
+
+		vote := models.ReviewVote{ReviewID: review.ID, UserID: userID}
+		var res *gorm.DB
+		delta := 1
+		if helpful {
+			res = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&vote)
+		} else {
+			res = tx.Where("review_id = ? AND user_id = ?", review.ID, userID).Delete(&models.ReviewVote{})
+			delta = -1
+		}
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		return tx.Model(review).UpdateColumn("helpful_count", gorm.Expr("helpful_count + ?", delta)).Error
+	})
+	if err != nil {
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	rc.DB.Model(&models.Review{}).Where("id = ?", reviewID).Select("helpful_count").Scan(&count)
+	c.JSON(http.StatusOK, gin.H{"review_id": reviewID, "helpful": helpful, "helpful_count": count})
+}
+
+// GetMovieRating - GET /movies/:id/rating (public)
+func (rc *ReviewController) GetMovieRating(c *gin.Context) {
+	movieID, _, ok := parseReviewParams(c)
+	if !ok {
+		return
+	}
+	agg, err := rc.ratingSummary(movieID)
+	if err != nil {
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, agg)
+}
+
+func (rc *ReviewController) ratingSummary(movieID uint) (*models.MovieRating, error) {
+	var count int64
+	if err := rc.DB.Model(&models.Movie{}).Where("id = ?", movieID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errMovieNotFound
+	}
+	agg := models.MovieRating{MovieID: movieID}
+	if err := rc.DB.Where("movie_id = ?", movieID).Limit(1).Find(&agg).Error; err != nil {
+		return nil, err
+	}
+	return &agg, nil
+}
+
+var reviewSortColumns = map[string]string{
+	"helpful": "helpful_count",
+	"recent":  "created_at",
+}
+
+// ListReviews - GET /movies/:id/reviews?sort=helpful|recent&limit=&cursor= (public)
+func (rc *ReviewController) ListReviews(c *gin.Context) {
+	movieID, _, ok := parseReviewParams(c)
+	if !ok {
+		return
+	}
+
+	sort := c.DefaultQuery("sort", "recent")
+	col, valid := reviewSortColumns[sort]
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort, use helpful or recent"})
+		return
+	}
+	limit := defaultMovieLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		if n > maxMovieLimit {
+			n = maxMovieLimit
+		}
+		limit = n
+	}
+
+	summary, err := rc.ratingSummary(movieID)
+	if err != nil {
+		c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	query := rc.DB.Where("movie_id = ?", movieID)
+	if raw := c.Query("cursor"); raw != "" {
+		cur, err := decodeCursor(raw)
+		if err != nil || cur.Sort != sort {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		cast := "bigint"
+		if sort == "recent" {
+			cast = "timestamptz"
+		}
+		query = query.Where("("+col+", id) < (CAST(? AS "+cast+"), ?)", cur.Value, cur.ID)
+	}
+
+	var reviews []models.Review
+	if err := query.Order(col + " DESC, id DESC").Limit(limit + 1).Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list reviews"})
+		return
+	}
+
+	var nextCursor *string
+	if len(reviews) > limit {
+		reviews = reviews[:limit]
+		last := reviews[len(reviews)-1]
+		value := strconv.FormatInt(last.HelpfulCount, 10)
+		if sort == "recent" {
+			value = last.CreatedAt.Format(time.RFC3339Nano)
+		}
+		cur := encodeCursor(listCursor{Sort: sort, Order: "desc", Value: value, ID: last.ID})
+		nextCursor = &cur
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        reviews,
+		"limit":       limit,
+		"next_cursor": nextCursor,
+		"summary":     summary,
+	})
+}
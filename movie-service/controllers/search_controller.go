@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"movie-service/media"
+	"movie-service/search"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchController struct {
+	Index *search.Index
+}
+
+// Search - GET /search?q=...&types=movies,actors,genres&limit=10 (public)
+// Returns ranked hits grouped by type, with <mark> highlighted snippets
+// (HTML-escaped catalog text).
+func (sc *SearchController) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	limit := 10
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		if n > 50 {
+			n = 50
+		}
+		limit = n
+	}
+
+	opts := search.Options{Limit: limit, Movies: true, Actors: true, Genres: true}
+	if raw := c.Query("types"); raw != "" {
+		opts.Movies, opts.Actors, opts.Genres = false, false, false
+		for _, t := range strings.Split(raw, ",") {
+			switch strings.TrimSpace(t) {
+			case "movies":
+				opts.Movies = true
+			case "actors":
+				opts.Actors = true
+			case "genres":
+				opts.Genres = true
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid types, use movies, actors or genres"})
+				return
+			}
+		}
+	}
+
+	res, err := sc.Index.Query(c.Request.Context(), q, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search"})
+		return
+	}
+	for i := range res.Movies {
+		res.Movies[i].PosterURL = media.URL(res.Movies[i].PosterMediaID)
+	}
+	for i := range res.Actors {
+		res.Actors[i].PhotoURL = media.URL(res.Actors[i].PhotoMediaID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":  q,
+		"movies": res.Movies,
+		"actors": res.Actors,
+		"genres": res.Genres,
+	})
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"movie-service/suggest"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SuggestController struct {
+	Index *suggest.Index
+}
+
+// Suggest - GET /suggest?q=...&limit=8&types=movie,actor (public)
+// Served from the in-memory prefix index, never touches the database.
+func (sc *SuggestController) Suggest(c *gin.Context) {
+	q := c.Query("q")
+	if strings.TrimSpace(q) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	limit := 8
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		if n > 20 {
+			n = 20
+		}
+		limit = n
+	}
+
+	var types map[string]bool
+	if raw := c.Query("types"); raw != "" {
+		types = map[string]bool{}
+		for _, t := range strings.Split(raw, ",") {
+			t = strings.TrimSpace(t)
+			if t != suggest.TypeMovie && t != suggest.TypeActor {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid types, use movie or actor"})
+				return
+			}
+			types[t] = true
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":       q,
+		"suggestions": sc.Index.Lookup(q, limit, types),
+	})
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"movie-service/handlers"
+	"movie-service/models"
+	"movie-service/views"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"movie-rest/pkg/auth"
+)
+
+type ViewController struct {
+	DB      *gorm.DB
+	Counter *views.Counter
+}
+
+type recordViewRequest struct {
+	SessionID string `json:"session_id"` // anonymous viewers, optional
+}
+
+// viewerKey identifies who is watching: the user auth.Optional found in a
+// valid bearer token (or X-User-ID on internal calls from user-service's
+// watch history), else the client IP together with the anonymous session id
+// (X-Session-ID header or body). For anonymous viewers it also returns the
+// IP, which caps how many made-up session ids count.
+func viewerKey(c *gin.Context, sessionID string) (string, string) {
+	if uid := c.GetHeader("X-User-ID"); uid != "" && handlers.IsInternalCall(c) {
+		if id, err := strconv.ParseUint(uid, 10, 64); err == nil && id > 0 {
+			return "u:" + uid, ""
+		}
+	}
+	if uid := auth.UserID(c); uid > 0 {
+		return "u:" + strconv.FormatUint(uint64(uid), 10), ""
+	}
+	ip := c.ClientIP()
+	if s := c.GetHeader("X-Session-ID"); s != "" {
+		sessionID = s
+	}
+	if sessionID != "" {
+		return "ip:" + ip + "|s:" + sessionID, ip
+	}
+	return "ip:" + ip, ip
+}
+
+// RecordView - POST /movies/:id/views (public)
+// Playback-start event. Counted at most once per viewer within the
+// deduplication window; the database is updated in batches.
+func (vc *ViewController) RecordView(c *gin.Context) {
+	id64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req recordViewRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var count int64
+	if err := vc.DB.Model(&models.Movie{}).Where("id = ?", uint(id64)).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query movie"})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "movie not found"})
+		return
+	}
+
+	viewer, ip := viewerKey(c, req.SessionID)
+	counted := vc.Counter.Record(uint(id64), viewer, ip, time.Now())
+	c.JSON(http.StatusAccepted, gin.H{"movie_id": id64, "counted": counted})
+}
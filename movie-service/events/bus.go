@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+type Type string
+
+const (
+	MovieSaved   Type = "movie.saved"
+	MovieDeleted Type = "movie.deleted"
+	ActorSaved   Type = "actor.saved"
+	ActorDeleted Type = "actor.deleted"
+	GenreSaved   Type = "genre.saved"
+	GenreDeleted Type = "genre.deleted"
+)
+
+// Event tells subscribers that a catalog row changed. For actor and genre
+// events MovieIDs lists the movies linked to it, which is the only way to
+// know them after a delete has cleared the join table.
+type Event struct {
+	Type     Type
+	ID       uint
+	MovieIDs []uint
+	// RatingOnly marks a MovieSaved where only the rating changed (a review
+	// was written, edited or removed)
+	RatingOnly bool
+}
+
+// Handler reacts to an event; returned errors are logged, not propagated
+type Handler func(ctx context.Context, e Event) error
+
+type subscriber struct {
+	name string
+	fn   Handler
+}
+
+// Bus delivers catalog events to in-process subscribers (search index,
+// suggestions, ...) synchronously and in subscription order. A nil *Bus
+// drops every event, so controllers work without one.
+type Bus struct {
+	mu   sync.RWMutex
+	subs []subscriber
+}
+
+func (b *Bus) Subscribe(name string, fn Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, subscriber{name: name, fn: fn})
+}
+
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		if err := s.fn(ctx, e); err != nil {
+			log.Printf("events: %s failed on %s #%d: %v", s.name, e.Type, e.ID, err)
+		}
+	}
+}
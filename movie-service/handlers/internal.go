@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IsInternalCall reports whether the request carries the shared
+// INTERNAL_API_TOKEN in X-Internal-Token, i.e. comes from another service
+func IsInternalCall(c *gin.Context) bool {
+	want := os.Getenv("INTERNAL_API_TOKEN")
+	got := c.GetHeader("X-Internal-Token")
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
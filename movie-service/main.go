@@ -1,26 +1,87 @@
 package main
 
 import (
+	"context"
 	"log"
 	"movie-service/connection"
 	"movie-service/controllers"
-	"movie-service/handlers"
+	"movie-service/events"
+	"movie-service/media"
+	"movie-service/recommend"
+	"movie-service/search"
+	"movie-service/suggest"
+	"movie-service/trending"
+	"movie-service/userclient"
+	"movie-service/views"
 
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"movie-rest/pkg/auth"
 )
 
 func main() {
 	_ = godotenv.Load(".env")
 
+	// cancelled on SIGINT/SIGTERM so background workers can flush
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	db := connection.Connect()
-	mc := controllers.MovieController{DB: db}
-	gc := controllers.GenreController{DB: db}
-	ac := controllers.ActorController{DB: db}
+	store, err := media.NewStoreFromEnv()
+	if err != nil {
+		log.Fatal("Failed to init media store:", err)
+	}
+	ms := media.NewService(db, store)
+
+	// search index and suggestions follow catalog changes through the event bus
+	bus := &events.Bus{}
+	si := &search.Index{DB: db}
+	if err := si.Setup(ctx); err != nil {
+		log.Fatal("Failed to set up search index:", err)
+	}
+	bus.Subscribe("search", si.Handle)
+
+	sx := suggest.NewIndex()
+	sl := &suggest.Loader{DB: db, Index: sx}
+	if err := sl.Start(ctx, 10*time.Minute); err != nil {
+		log.Fatal("Failed to load suggestions:", err)
+	}
+	bus.Subscribe("suggest", sl.Handle)
+
+	rb := recommend.NewBuilder(db)
+	rb.Start(ctx)
+	bus.Subscribe("recommend", rb.Handle)
+
+	// user-service forgets deleted movies and publishes the token keys
+	uc := userclient.NewFromEnv()
+	verifier := auth.NewVerifier(auth.NewJWKSFromEnv().Keyfunc, auth.SQLDenylist(db))
+	bus.Subscribe("users", uc.Handle)
+
+	mc := controllers.MovieController{DB: db, Media: ms, Events: bus, Users: uc}
+	gc := controllers.GenreController{DB: db, Events: bus}
+	ac := controllers.ActorController{DB: db, Media: ms, Events: bus}
+	mdc := controllers.MediaController{Media: ms}
+	sc := controllers.SearchController{Index: si}
+	sgc := controllers.SuggestController{Index: sx}
+	rvc := controllers.ReviewController{DB: db, Events: bus}
+
+	// the counter outlives the HTTP server so views recorded by draining
+	// requests still make it into the final flush
+	counterCtx, stopCounter := context.WithCancel(context.Background())
+	counter := views.NewCounter(db)
+	tj := trending.NewJob(db)
+	counter.OnFlush = tj.RecordViews
+	tj.Start(ctx)
+	counterDone := counter.Start(counterCtx)
+	vc := controllers.ViewController{DB: db, Counter: counter}
+	fyc := controllers.ForYouController{DB: db, Users: uc, Builder: rb}
 
 	r := gin.Default()
 
@@ -33,41 +94,89 @@ func main() {
 		AllowCredentials: true,
 	}))
 
-	// Movie public
-	r.GET("/movies", mc.GetMovies)
-	r.GET("/movies/:id", mc.GetMovieByID)
-	r.GET("/movies/trending", mc.GetTrendingMovies)
-	r.GET("/movies/:id/recommendations", mc.GetMovieRecommendations)
+	// Public endpoints; a valid token still identifies the caller, for
+	// premium movies, watchlist flags and view counting
+	public := r.Group("/")
+	public.Use(verifier.Optional())
+	{
+		// Movie public
+		public.GET("/movies", mc.GetMovies)
+		public.GET("/movies/:id", mc.GetMovieByID)
+		public.GET("/movies/trending", mc.GetTrendingMovies)
+		public.GET("/movies/:id/recommendations", mc.GetMovieRecommendations)
+		public.GET("/movies/:id/reviews", rvc.ListReviews)
+		public.GET("/movies/:id/rating", rvc.GetMovieRating)
+		public.POST("/movies/:id/views", vc.RecordView)
+
+		// Genre public
+		public.GET("/genres", gc.ListGenres)
+		public.GET("/genres/:id", gc.GetGenre)
+
+		// Actor public
+		public.GET("/actors", ac.ListActors)
+		public.GET("/actors/:id", ac.GetActor)
 
-	// Genre public
-	r.GET("/genres", gc.ListGenres)
-	r.GET("/genres/:id", gc.GetGenre)
+		// Search public
+		public.GET("/search", sc.Search)
+		public.GET("/suggest", sgc.Suggest)
 
-	// Actor public
-	r.GET("/actors", ac.ListActors)
-	r.GET("/actors/:id", ac.GetActor)
+		// Media public
+		public.GET("/media/:id", mdc.GetMedia)
+	}
 
-	// Protected endpoints
+	// Protected endpoints, any signed-in user
 	protected := r.Group("/")
-	protected.Use(handlers.AuthMiddleware())
+	protected.Use(verifier.Required())
+	{
+		protected.POST("/movies/:id/reviews", rvc.CreateReview)
+		protected.PATCH("/movies/:id/reviews/:reviewId", rvc.UpdateReview)
+		protected.DELETE("/movies/:id/reviews/:reviewId", rvc.DeleteReview)
+		protected.POST("/movies/:id/reviews/:reviewId/helpful", rvc.MarkHelpful)
+		protected.DELETE("/movies/:id/reviews/:reviewId/helpful", rvc.UnmarkHelpful)
+
+		protected.GET("/me/recommendations", fyc.GetForYou)
+	}
+
+	// Catalog management, editors and admins only
+	catalog := r.Group("/")
+	catalog.Use(verifier.Required(), auth.RequirePermission(auth.PermCatalogWrite))
 	{
-		protected.POST("/movies", mc.CreateMovie)
-		protected.PATCH("/movies/:id", mc.UpdateMovie)
-		protected.DELETE("/movies/:id", mc.DeleteMovie)
+		catalog.POST("/movies", mc.CreateMovie)
+		catalog.PATCH("/movies/:id", mc.UpdateMovie)
+		catalog.DELETE("/movies/:id", mc.DeleteMovie)
+		catalog.POST("/movies/:id/poster", mc.UploadPoster)
+
+		catalog.POST("/genres", gc.CreateGenre)
+		catalog.PATCH("/genres/:id", gc.UpdateGenre)
+		catalog.DELETE("/genres/:id", gc.DeleteGenre)
 
-		protected.POST("/genres", gc.CreateGenre)
-		protected.PATCH("/genres/:id", gc.UpdateGenre)
-		protected.DELETE("/genres/:id", gc.DeleteGenre)
+		catalog.POST("/actors", ac.CreateActor)
+		catalog.PATCH("/actors/:id", ac.UpdateActor)
+		catalog.DELETE("/actors/:id", ac.DeleteActor)
+		catalog.POST("/actors/:id/photo", ac.UploadPhoto)
 
-		protected.POST("/actors", ac.CreateActor)
-		protected.PATCH("/actors/:id", ac.UpdateActor)
-		protected.DELETE("/actors/:id", ac.DeleteActor)
+		catalog.POST("/media", mdc.UploadMedia)
 	}
 
 	port := os.Getenv("MOVIE_SERVICE_PORT")
 	if port == "" {
 		port = "8002"
 	}
-	log.Println("Movie service running on :" + port)
-	r.Run(":" + port)
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		log.Println("Movie service running on :" + port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Server failed:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down movie service")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("Server shutdown:", err)
+	}
+	stopCounter()
+	<-counterDone
 }
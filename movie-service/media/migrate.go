@@ -0,0 +1,115 @@
+package media
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// legacyColumn describes one of the old base64 text columns
+type legacyColumn struct {
+	Table    string
+	Column   string
+	MediaCol string
+}
+
+var legacyColumns = []legacyColumn{
+	{Table: "movies", Column: "poster_base64", MediaCol: "poster_media_id"},
+	{Table: "actors", Column: "photo_base64", MediaCol: "photo_media_id"},
+}
+
+// MigrateStats reports what MigrateBase64 did per table
+type MigrateStats struct {
+	Table    string
+	Migrated int
+	Failed   int
+}
+
+// MigrateBase64 moves images stored in the legacy poster_base64/photo_base64
+// columns into the BlobStore and points poster_media_id/photo_media_id at the
+// new media rows. It only touches rows that have no media id yet, so it is
+// safe to run again after a partial failure. With dropColumns the legacy
+// columns are dropped once every row of a table migrated cleanly.
+func MigrateBase64(ctx context.Context, s *Service, dropColumns bool) ([]MigrateStats, error) {
+	var out []MigrateStats
+	for _, lc := range legacyColumns {
+		if !s.DB.Migrator().HasColumn(lc.Table, lc.Column) {
+			log.Printf("%s.%s does not exist, skipping", lc.Table, lc.Column)
+			continue
+		}
+		st, err := migrateColumn(ctx, s, lc)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, st)
+
+		if dropColumns && st.Failed == 0 {
+			if err := s.DB.Migrator().DropColumn(lc.Table, lc.Column); err != nil {
+				return out, fmt.Errorf("drop %s.%s: %w", lc.Table, lc.Column, err)
+			}
+			log.Printf("dropped %s.%s", lc.Table, lc.Column)
+		}
+	}
+	return out, nil
+}
+
+func migrateColumn(ctx context.Context, s *Service, lc legacyColumn) (MigrateStats, error) {
+	st := MigrateStats{Table: lc.Table}
+
+	type row struct {
+		ID   uint
+		Data string
+	}
+	var lastID uint
+	for {
+		// diproses per batch supaya tidak memuat semua gambar sekaligus ke memori
+		var rows []row
+		q := fmt.Sprintf(`SELECT id, %s AS data FROM %s
+			WHERE id > ? AND %s IS NULL AND COALESCE(%s, '') <> ''
+			ORDER BY id LIMIT 50`, lc.Column, lc.Table, lc.MediaCol, lc.Column)
+		if err := s.DB.WithContext(ctx).Raw(q, lastID).Scan(&rows).Error; err != nil {
+			return st, err
+		}
+		if len(rows) == 0 {
+			return st, nil
+		}
+
+		for _, r := range rows {
+			lastID = r.ID
+			data, err := decodeLegacyBase64(r.Data)
+			if err != nil {
+				log.Printf("%s #%d: %v", lc.Table, r.ID, err)
+				st.Failed++
+				continue
+			}
+			m, err := s.SaveBytes(ctx, data)
+			if err != nil {
+				log.Printf("%s #%d: %v", lc.Table, r.ID, err)
+				st.Failed++
+				continue
+			}
+			upd := fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", lc.Table, lc.MediaCol)
+			if err := s.DB.WithContext(ctx).Exec(upd, m.ID, r.ID).Error; err != nil {
+				_ = s.Delete(ctx, &m.ID)
+				return st, err
+			}
+			st.Migrated++
+		}
+	}
+}
+
+// decodeLegacyBase64 accepts plain base64 as well as "data:image/png;base64,..." URIs
+func decodeLegacyBase64(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "data:") {
+		if i := strings.Index(s, ","); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	if data, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return data, nil
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,200 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"movie-service/models"
+
+	"gorm.io/gorm"
+)
+
+const defaultMaxBytes = 5 << 20 // 5 MB
+
+// maxDecodePixels caps Width*Height of the images accepted and decoded. A
+// small compressed file can declare a huge canvas, and decoding allocates
+// all of it (4 bytes per pixel).
+const maxDecodePixels = 40_000_000
+
+var (
+	ErrTooLarge        = errors.New("file too large")
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrEmpty           = errors.New("empty file")
+	ErrTooManyPixels   = errors.New("image dimensions too large")
+)
+
+// allowed image types, detected from the content rather than the client's header
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Service stores uploads in a BlobStore and records them in the media table
+type Service struct {
+	DB       *gorm.DB
+	Store    BlobStore
+	MaxBytes int64
+	Widths   []int // variant widths generated for every image, ascending
+}
+
+func NewService(db *gorm.DB, store BlobStore) *Service {
+	max := int64(defaultMaxBytes)
+	if v, err := strconv.ParseInt(os.Getenv("MEDIA_MAX_BYTES"), 10, 64); err == nil && v > 0 {
+		max = v
+	}
+	return &Service{DB: db, Store: store, MaxBytes: max, Widths: variantWidthsFromEnv()}
+}
+
+// URL returns the public URL of a media row, or "" when id is nil.
+// MEDIA_BASE_URL may point at a CDN or the service's public address.
+func URL(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return os.Getenv("MEDIA_BASE_URL") + "/media/" + strconv.FormatUint(uint64(*id), 10)
+}
+
+// SniffType returns the detected image content type of data
+func SniffType(data []byte) (string, error) {
+	ct := http.DetectContentType(data)
+	if !allowedTypes[ct] {
+		return "", ErrUnsupportedType
+	}
+	return ct, nil
+}
+
+// Save reads an upload (at most MaxBytes), checks that it is an image and stores it
+func (s *Service) Save(ctx context.Context, r io.Reader) (*models.Media, error) {
+	data, err := io.ReadAll(io.LimitReader(r, s.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.MaxBytes {
+		return nil, ErrTooLarge
+	}
+	return s.SaveBytes(ctx, data)
+}
+
+// SaveBytes stores an in-memory image
+func (s *Service) SaveBytes(ctx context.Context, data []byte) (*models.Media, error) {
+	if len(data) == 0 {
+		return nil, ErrEmpty
+	}
+	if int64(len(data)) > s.MaxBytes {
+		return nil, ErrTooLarge
+	}
+	ct, err := SniffType(data)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, cfgErr := image.DecodeConfig(bytes.NewReader(data))
+	if cfgErr == nil && int64(cfg.Width)*int64(cfg.Height) > maxDecodePixels {
+		return nil, ErrTooManyPixels
+	}
+
+	sum := sha256.Sum256(data)
+	key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), ct); err != nil {
+		return nil, fmt.Errorf("store blob: %w", err)
+	}
+
+	m := models.Media{
+		Key:         key,
+		ContentType: ct,
+		Size:        int64(len(data)),
+		Checksum:    hex.EncodeToString(sum[:]),
+	}
+	if cfgErr == nil {
+		m.Width, m.Height = cfg.Width, cfg.Height
+	}
+	if err := s.DB.WithContext(ctx).Create(&m).Error; err != nil {
+		_ = s.Store.Delete(ctx, key)
+		return nil, err
+	}
+
+	// the original is usable without variants, so a failure here is not fatal
+	if err := s.EnsureVariants(ctx, m.ID); err != nil {
+		log.Printf("media %d: failed to generate variants: %v", m.ID, err)
+	}
+	return s.Find(ctx, m.ID)
+}
+
+// Find loads a media row and its variants by id
+func (s *Service) Find(ctx context.Context, id uint) (*models.Media, error) {
+	var m models.Media
+	if err := s.DB.WithContext(ctx).Preload("Variants").First(&m, id).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Open streams the bytes of m, or of variant v when it is not nil
+func (s *Service) Open(ctx context.Context, m *models.Media, v *models.MediaVariant) (io.ReadCloser, error) {
+	if v != nil {
+		return s.Store.Get(ctx, v.Key)
+	}
+	return s.Store.Get(ctx, m.Key)
+}
+
+// Delete removes the media row and its blob. A nil id is a no-op, and so
+// is an id still used as a poster or photo: the same upload can be given to
+// several movies and actors.
+func (s *Service) Delete(ctx context.Context, id *uint) error {
+	if id == nil {
+		return nil
+	}
+	var refs int64
+	if err := s.DB.WithContext(ctx).Raw(`SELECT
+		(SELECT COUNT(*) FROM movies WHERE poster_media_id = ?) +
+		(SELECT COUNT(*) FROM actors WHERE photo_media_id = ?)`, *id, *id).Scan(&refs).Error; err != nil {
+		return err
+	}
+	if refs > 0 {
+		return nil
+	}
+	m, err := s.Find(ctx, *id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.DB.WithContext(ctx).Where("media_id = ?", m.ID).Delete(&models.MediaVariant{}).Error; err != nil {
+		return err
+	}
+	if err := s.DB.WithContext(ctx).Delete(m).Error; err != nil {
+		return err
+	}
+	for _, v := range m.Variants {
+		if err := s.Store.Delete(ctx, v.Key); err != nil {
+			return err
+		}
+	}
+	return s.Store.Delete(ctx, m.Key)
+}
+
+// newKey returns a random key fanned out over 256 prefixes, e.g. "3f/3fa1..."
+func newKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	h := hex.EncodeToString(b)
+	return h[:2] + "/" + h, nil
+}
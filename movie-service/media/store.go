@@ -0,0 +1,42 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrBlobNotFound is returned by a BlobStore when the key does not exist
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps the raw bytes of uploaded media. Keys are opaque,
+// slash-separated paths chosen by Service.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStoreFromEnv builds the BlobStore selected by MEDIA_STORE (local or s3)
+func NewStoreFromEnv() (BlobStore, error) {
+	switch os.Getenv("MEDIA_STORE") {
+	case "", "local":
+		dir := os.Getenv("MEDIA_LOCAL_DIR")
+		if dir == "" {
+			dir = "./uploads"
+		}
+		return NewLocalStore(dir)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown MEDIA_STORE %q", os.Getenv("MEDIA_STORE"))
+	}
+}
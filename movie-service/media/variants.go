@@ -0,0 +1,237 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "image/gif" // register decoder
+
+	"movie-service/models"
+
+	"gorm.io/gorm"
+)
+
+var defaultVariantWidths = []int{150, 300, 600}
+
+// variantWidthsFromEnv parses MEDIA_VARIANT_WIDTHS, e.g. "150,300,600"
+func variantWidthsFromEnv() []int {
+	raw := os.Getenv("MEDIA_VARIANT_WIDTHS")
+	if raw == "" {
+		return defaultVariantWidths
+	}
+	var out []int
+	for _, p := range strings.Split(raw, ",") {
+		if w, err := strconv.Atoi(strings.TrimSpace(p)); err == nil && w > 0 {
+			out = append(out, w)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// canResize reports whether the standard library can decode and re-encode ct.
+// WebP has no encoder/decoder in the standard library, so WebP uploads are
+// served as-is without variants.
+func canResize(ct string) bool {
+	return ct == "image/jpeg" || ct == "image/png" || ct == "image/gif"
+}
+
+// EnsureVariants generates the configured widths that m does not have yet.
+// It is called after every upload and whenever a movie or actor gets a new
+// image, so renditions also appear for media stored before a width was added.
+func (s *Service) EnsureVariants(ctx context.Context, id uint) error {
+	var m models.Media
+	if err := s.DB.WithContext(ctx).Preload("Variants").First(&m, id).Error; err != nil {
+		return err
+	}
+	if !canResize(m.ContentType) {
+		return nil
+	}
+
+	have := make(map[int]bool, len(m.Variants))
+	for _, v := range m.Variants {
+		have[v.Width] = true
+	}
+	var missing []int
+	for _, w := range s.Widths {
+		// never upscale
+		if !have[w] && w < m.Width {
+			missing = append(missing, w)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	rc, err := s.Store.Get(ctx, m.Key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	// read the header first, so a decompression bomb is refused before its
+	// pixels are allocated
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decode media %d: %w", m.ID, err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxDecodePixels {
+		return fmt.Errorf("media %d is %dx%d: %w", m.ID, cfg.Width, cfg.Height, ErrTooManyPixels)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decode media %d: %w", m.ID, err)
+	}
+
+	for _, w := range missing {
+		if err := s.storeVariant(ctx, &m, src, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) storeVariant(ctx context.Context, m *models.Media, src image.Image, width int) error {
+	dst := resizeToWidth(src, width)
+
+	var buf bytes.Buffer
+	ct := "image/png"
+	if m.ContentType == "image/jpeg" {
+		ct = "image/jpeg"
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+			return err
+		}
+	} else if err := png.Encode(&buf, dst); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s_w%d", m.Key, width)
+	data := buf.Bytes()
+	if err := s.Store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), ct); err != nil {
+		return fmt.Errorf("store variant: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	v := models.MediaVariant{
+		MediaID:     m.ID,
+		Width:       dst.Bounds().Dx(),
+		Height:      dst.Bounds().Dy(),
+		Key:         key,
+		ContentType: ct,
+		Size:        int64(len(data)),
+		Checksum:    hex.EncodeToString(sum[:]),
+	}
+	if err := s.DB.WithContext(ctx).Create(&v).Error; err != nil {
+		_ = s.Store.Delete(ctx, key)
+		return err
+	}
+	return nil
+}
+
+// PickVariant returns the smallest variant at least w pixels wide, or nil
+// when the original should be served
+func PickVariant(m *models.Media, w int) *models.MediaVariant {
+	if w <= 0 {
+		return nil
+	}
+	var best *models.MediaVariant
+	for i := range m.Variants {
+		v := &m.Variants[i]
+		if v.Width >= w && (best == nil || v.Width < best.Width) {
+			best = v
+		}
+	}
+	return best
+}
+
+// Srcsets returns a srcset attribute value ("url?w=150 150w, ...") for each
+// media id that has variants; the original is listed last at its own width
+func (s *Service) Srcsets(ctx context.Context, ids []uint) (map[uint]string, error) {
+	out := make(map[uint]string)
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var list []models.Media
+	if err := s.DB.WithContext(ctx).Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("width")
+	}).Find(&list, ids).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range list {
+		if len(m.Variants) == 0 {
+			continue
+		}
+		base := URL(&m.ID)
+		parts := make([]string, 0, len(m.Variants)+1)
+		for _, v := range m.Variants {
+			parts = append(parts, fmt.Sprintf("%s?w=%d %dw", base, v.Width, v.Width))
+		}
+		if m.Width > 0 {
+			parts = append(parts, fmt.Sprintf("%s %dw", base, m.Width))
+		}
+		out[m.ID] = strings.Join(parts, ", ")
+	}
+	return out, nil
+}
+
+// resizeToWidth downscales src to the given width keeping the aspect ratio.
+// Each destination pixel is the average of the source pixels it covers (box
+// filter), which is cheap and avoids aliasing when shrinking.
+func resizeToWidth(src image.Image, width int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		sy0 := y * sh / height
+		sy1 := (y + 1) * sh / height
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < width; x++ {
+			sx0 := x * sw / width
+			sx1 := (x + 1) * sw / width
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(b.Min.X+sx, b.Min.Y+sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
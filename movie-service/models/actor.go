@@ -1,9 +1,10 @@
 package models
 
 type Actor struct {
-	ID       uint   `gorm:"primaryKey" json:"id"`
-	Name     string `gorm:"type:varchar(100)" json:"name"`
-	PhotoBase64  string `gorm:"type:text" json:"photo_url"`
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Name         string `gorm:"type:varchar(100)" json:"name"`
+	PhotoMediaID *uint  `json:"photo_media_id"`
+	PhotoURL     string `gorm:"-" json:"photo_url"` // filled by the controller from PhotoMediaID
 
 	Movies []Movie `gorm:"many2many:movie_actors" json:"-"`
 }
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Media is an uploaded image; the bytes live in the media BlobStore under Key
+type Media struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Key         string    `gorm:"uniqueIndex;type:varchar(255)" json:"-"`
+	ContentType string    `gorm:"type:varchar(50)" json:"content_type"`
+	Size        int64     `json:"size"`
+	Checksum    string    `gorm:"type:char(64)" json:"checksum"` // sha256 hex, used as ETag
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Variants []MediaVariant `gorm:"foreignKey:MediaID" json:"variants,omitempty"`
+}
+
+// MediaVariant is a downscaled rendition of a Media, stored next to the
+// original under "<media key>_w<width>"
+type MediaVariant struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	MediaID     uint      `gorm:"uniqueIndex:idx_media_variant_width" json:"media_id"`
+	Width       int       `gorm:"uniqueIndex:idx_media_variant_width" json:"width"`
+	Height      int       `json:"height"`
+	Key         string    `gorm:"uniqueIndex;type:varchar(255)" json:"-"`
+	ContentType string    `gorm:"type:varchar(50)" json:"content_type"`
+	Size        int64     `json:"size"`
+	Checksum    string    `gorm:"type:char(64)" json:"checksum"`
+	CreatedAt   time.Time `json:"created_at"`
+}
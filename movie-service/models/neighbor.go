@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// MovieNeighbor is one precomputed "similar movie" of a movie, ranked by
+// score (1 = most similar).
+type MovieNeighbor struct {
+	MovieID    uint      `gorm:"primaryKey;index:idx_movie_neighbors_rank,priority:1" json:"movie_id"`
+	NeighborID uint      `gorm:"primaryKey;index" json:"neighbor_id"`
+	Rank       int       `gorm:"index:idx_movie_neighbors_rank,priority:2" json:"rank"`
+	Score      float64   `json:"score"`
+	Reason     string    `gorm:"type:varchar(255)" json:"reason"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// MovieCooccurrence says how often users who liked MovieID also liked
+// OtherID. Similarity is the cosine of the two movies' user sets.
+type MovieCooccurrence struct {
+	MovieID    uint    `gorm:"primaryKey" json:"movie_id"`
+	OtherID    uint    `gorm:"primaryKey" json:"other_id"`
+	Users      int64   `json:"users"`
+	Similarity float64 `json:"similarity"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Review is a user's 1-10 score and optional text for a movie; one per user and movie
+type Review struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	MovieID      uint      `gorm:"uniqueIndex:idx_reviews_movie_user;not null" json:"movie_id"`
+	UserID       uint      `gorm:"uniqueIndex:idx_reviews_movie_user;index;not null" json:"user_id"`
+	Score        int       `gorm:"not null" json:"score"`
+	Body         string    `gorm:"type:text" json:"body"`
+	HelpfulCount int64     `gorm:"not null;default:0" json:"helpful_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ReviewVote records that a user marked a review as helpful
+type ReviewVote struct {
+	ReviewID  uint `gorm:"primaryKey"`
+	UserID    uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// Histogram counts reviews per score; index 0 holds score 1
+type Histogram [10]int64
+
+func (h Histogram) Value() (driver.Value, error) {
+	b, err := json.Marshal(h)
+	return string(b), err
+}
+
+func (h *Histogram) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, h)
+	case string:
+		return json.Unmarshal([]byte(v), h)
+	case nil:
+		*h = Histogram{}
+		return nil
+	}
+	return errors.New("unsupported histogram value")
+}
+
+// MovieRating is the review aggregate of a movie; Average is copied into Movie.Rating
+type MovieRating struct {
+	MovieID   uint      `gorm:"primaryKey" json:"movie_id"`
+	Count     int64     `json:"count"`
+	Average   float64   `json:"average"`
+	Histogram Histogram `gorm:"type:jsonb" json:"histogram"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
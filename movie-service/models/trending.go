@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// MovieActivity holds views counted for a movie within one hour
+type MovieActivity struct {
+	MovieID uint      `gorm:"primaryKey"`
+	Bucket  time.Time `gorm:"primaryKey;index"` // start of the hour, UTC
+	Views   int64
+}
+
+// TrendingScore is one row of the materialized trending ranking of a window
+type TrendingScore struct {
+	Period     string    `gorm:"primaryKey;type:varchar(10)" json:"window"` // day, week or month
+	MovieID    uint      `gorm:"primaryKey" json:"movie_id"`
+	Rank       int       `gorm:"index" json:"rank"`
+	Score      float64   `json:"score"`
+	ComputedAt time.Time `json:"computed_at"`
+}
@@ -0,0 +1,70 @@
+package recommend
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// a review at or above this score counts as liking the movie
+const likedScore = 7
+
+// cooccurrenceSQL pairs every two movies liked by the same user and keeps
+// the best @keep pairs per movie by cosine similarity. Saving a movie to a
+// watchlist or any other list counts as a like.
+const cooccurrenceSQL = `INSERT INTO movie_cooccurrences (movie_id, other_id, users, similarity)
+	WITH likes AS (
+		SELECT DISTINCT s.user_id, s.movie_id FROM (
+			SELECT user_id, movie_id FROM reviews WHERE score >= @liked
+			%WATCHLIST%
+		) s
+		JOIN movies m ON m.id = s.movie_id
+	),
+	counts AS (
+		SELECT movie_id, COUNT(*) AS n FROM likes GROUP BY movie_id
+	),
+	pairs AS (
+		SELECT a.movie_id, b.movie_id AS other_id, COUNT(*) AS users
+		FROM likes a
+		JOIN likes b ON b.user_id = a.user_id AND b.movie_id <> a.movie_id
+		GROUP BY a.movie_id, b.movie_id
+		HAVING COUNT(*) >= @min_users
+	),
+	ranked AS (
+		SELECT p.movie_id, p.other_id, p.users,
+			p.users / sqrt(ca.n * cb.n) AS similarity,
+			ROW_NUMBER() OVER (PARTITION BY p.movie_id ORDER BY p.users / sqrt(ca.n * cb.n) DESC, p.other_id) AS rn
+		FROM pairs p
+		JOIN counts ca ON ca.movie_id = p.movie_id
+		JOIN counts cb ON cb.movie_id = p.other_id
+	)
+	SELECT movie_id, other_id, users, similarity FROM ranked WHERE rn <= @keep`
+
+const watchlistLikesSQL = `UNION ALL
+			SELECT l.user_id, i.movie_id FROM list_items i JOIN lists l ON l.id = i.list_id`
+
+// RebuildCooccurrence recomputes movie_cooccurrences from reviews and
+// saved lists of all users
+func (b *Builder) RebuildCooccurrence(ctx context.Context) error {
+	db := b.DB.WithContext(ctx)
+
+	// lists belong to user-service; they may not exist yet
+	sql := cooccurrenceSQL
+	if db.Migrator().HasTable("list_items") {
+		sql = strings.Replace(sql, "%WATCHLIST%", watchlistLikesSQL, 1)
+	} else {
+		sql = strings.Replace(sql, "%WATCHLIST%", "", 1)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM movie_cooccurrences").Error; err != nil {
+			return err
+		}
+		return tx.Exec(sql, map[string]interface{}{
+			"liked":     likedScore,
+			"min_users": b.CFMinUsers,
+			"keep":      b.Keep,
+		}).Error
+	})
+}
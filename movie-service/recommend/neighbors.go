@@ -0,0 +1,472 @@
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"movie-service/events"
+	"movie-service/models"
+
+	"gorm.io/gorm"
+)
+
+// Weights says how much each signal contributes to the similarity score.
+// Every signal is normalized to [0, 1] before weighting.
+type Weights struct {
+	Genre      float64 // Jaccard overlap of the genre sets
+	Actor      float64 // shared actors, saturating at 3
+	Year       float64 // release-year proximity
+	Rating     float64 // candidate rating / 10
+	Popularity float64 // log views relative to the most viewed movie
+}
+
+const (
+	// actors shared beyond this count add nothing
+	actorSaturation = 3
+	// years apart at which year proximity drops to 1/e
+	yearScale = 10.0
+)
+
+// Builder precomputes the neighbor list of every movie into movie_neighbors
+// and the item-item co-occurrence lists into movie_cooccurrences. Neighbor
+// lists are refreshed from catalog events, in the background and batched
+// over RefreshDelay; both are rebuilt every Interval to pick up rating,
+// views and taste drift.
+type Builder struct {
+	DB           *gorm.DB
+	Weights      Weights
+	Keep         int
+	Interval     time.Duration
+	RefreshDelay time.Duration
+
+	// CFWeight is the share of the collaborative component in personal
+	// recommendations; the rest is content similarity.
+	CFWeight float64
+	// pairs liked by fewer users are ignored as noise
+	CFMinUsers int
+
+	mu      sync.Mutex
+	pending map[uint]bool // movies waiting for a refresh
+	wake    chan struct{}
+}
+
+func NewBuilder(db *gorm.DB) *Builder {
+	keep := 50
+	if n, err := strconv.Atoi(os.Getenv("RECOMMEND_NEIGHBORS")); err == nil && n > 0 {
+		keep = n
+	}
+	minUsers := 2
+	if n, err := strconv.Atoi(os.Getenv("RECOMMEND_CF_MIN_USERS")); err == nil && n > 0 {
+		minUsers = n
+	}
+	interval := time.Hour
+	if d, err := time.ParseDuration(os.Getenv("RECOMMEND_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+	delay := 5 * time.Second
+	if d, err := time.ParseDuration(os.Getenv("RECOMMEND_REFRESH_DELAY")); err == nil && d >= 0 {
+		delay = d
+	}
+	return &Builder{
+		DB: db,
+		Weights: Weights{
+			Genre:      floatEnv("RECOMMEND_WEIGHT_GENRE", 0.4),
+			Actor:      floatEnv("RECOMMEND_WEIGHT_ACTOR", 0.25),
+			Year:       floatEnv("RECOMMEND_WEIGHT_YEAR", 0.15),
+			Rating:     floatEnv("RECOMMEND_WEIGHT_RATING", 0.1),
+			Popularity: floatEnv("RECOMMEND_WEIGHT_POPULARITY", 0.1),
+		},
+		Keep:         keep,
+		Interval:     interval,
+		RefreshDelay: delay,
+		CFWeight:     floatEnv("RECOMMEND_CF_WEIGHT", 0.5),
+		CFMinUsers:   minUsers,
+		pending:      map[uint]bool{},
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// floatEnv reads a weight; negative or non-finite values fall back to def,
+// since a negative weight would rank the least similar movies first
+func floatEnv(key string, def float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return def
+	}
+	return v
+}
+
+type feature struct {
+	ID     uint
+	Year   int
+	Rating float64
+	Views  int64
+	Genres map[uint]bool
+	Actors map[uint]bool
+}
+
+// catalog is a snapshot of everything the score needs
+type catalog struct {
+	movies     map[uint]*feature
+	byGenre    map[uint][]uint
+	byActor    map[uint][]uint
+	actorNames map[uint]string
+	maxViews   int64
+}
+
+type pair struct {
+	MovieID uint
+	OtherID uint
+}
+
+func loadCatalog(db *gorm.DB) (*catalog, error) {
+	var movies []struct {
+		ID          uint
+		ReleaseYear int
+		Rating      float64
+		Views       int64
+	}
+	if err := db.Raw(`SELECT id, release_year, rating, views FROM movies`).Scan(&movies).Error; err != nil {
+		return nil, err
+	}
+	var genres, actors []pair
+	if err := db.Raw(`SELECT movie_id, genre_id AS other_id FROM movie_genres`).Scan(&genres).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Raw(`SELECT movie_id, actor_id AS other_id FROM movie_actors`).Scan(&actors).Error; err != nil {
+		return nil, err
+	}
+	var names []struct {
+		ID   uint
+		Name string
+	}
+	if err := db.Raw(`SELECT id, name FROM actors`).Scan(&names).Error; err != nil {
+		return nil, err
+	}
+
+	cat := &catalog{
+		movies:     make(map[uint]*feature, len(movies)),
+		byGenre:    make(map[uint][]uint),
+		byActor:    make(map[uint][]uint),
+		actorNames: make(map[uint]string, len(names)),
+	}
+	for _, m := range movies {
+		cat.movies[m.ID] = &feature{
+			ID: m.ID, Year: m.ReleaseYear, Rating: m.Rating, Views: m.Views,
+			Genres: map[uint]bool{}, Actors: map[uint]bool{},
+		}
+		if m.Views > cat.maxViews {
+			cat.maxViews = m.Views
+		}
+	}
+	for _, g := range genres {
+		if f, ok := cat.movies[g.MovieID]; ok {
+			f.Genres[g.OtherID] = true
+			cat.byGenre[g.OtherID] = append(cat.byGenre[g.OtherID], g.MovieID)
+		}
+	}
+	for _, a := range actors {
+		if f, ok := cat.movies[a.MovieID]; ok {
+			f.Actors[a.OtherID] = true
+			cat.byActor[a.OtherID] = append(cat.byActor[a.OtherID], a.MovieID)
+		}
+	}
+	for _, n := range names {
+		cat.actorNames[n.ID] = n.Name
+	}
+	return cat, nil
+}
+
+// related returns the movies sharing at least one genre or actor with id
+func (cat *catalog) related(id uint) []uint {
+	f, ok := cat.movies[id]
+	if !ok {
+		return nil
+	}
+	seen := map[uint]bool{id: true}
+	var out []uint
+	add := func(ids []uint) {
+		for _, other := range ids {
+			if !seen[other] {
+				seen[other] = true
+				out = append(out, other)
+			}
+		}
+	}
+	for g := range f.Genres {
+		add(cat.byGenre[g])
+	}
+	for a := range f.Actors {
+		add(cat.byActor[a])
+	}
+	return out
+}
+
+// neighbors scores every related movie of id and returns the best keep of
+// them. Ties are broken by movie id so the ranking is deterministic.
+func (cat *catalog) neighbors(id uint, w Weights, keep int, now time.Time) []models.MovieNeighbor {
+	f, ok := cat.movies[id]
+	if !ok {
+		return nil
+	}
+
+	out := make([]models.MovieNeighbor, 0)
+	for _, otherID := range cat.related(id) {
+		o := cat.movies[otherID]
+
+		sharedGenres := 0
+		for g := range o.Genres {
+			if f.Genres[g] {
+				sharedGenres++
+			}
+		}
+		var sharedActors []string
+		for a := range o.Actors {
+			if f.Actors[a] {
+				sharedActors = append(sharedActors, cat.actorNames[a])
+			}
+		}
+
+		jaccard := 0.0
+		if union := len(f.Genres) + len(o.Genres) - sharedGenres; union > 0 {
+			jaccard = float64(sharedGenres) / float64(union)
+		}
+		actorSim := math.Min(float64(len(sharedActors)), actorSaturation) / actorSaturation
+		yearSim := 0.0
+		if f.Year > 0 && o.Year > 0 {
+			yearSim = math.Exp(-math.Abs(float64(f.Year-o.Year)) / yearScale)
+		}
+		popularity := 0.0
+		if cat.maxViews > 0 {
+			popularity = math.Log1p(float64(o.Views)) / math.Log1p(float64(cat.maxViews))
+		}
+
+		score := w.Genre*jaccard + w.Actor*actorSim + w.Year*yearSim +
+			w.Rating*o.Rating/10 + w.Popularity*popularity
+
+		out = append(out, models.MovieNeighbor{
+			MovieID:    id,
+			NeighborID: otherID,
+			// rounded so float noise cannot reorder equal scores
+			Score:      math.Round(score*1e4) / 1e4,
+			Reason:     reason(sharedGenres, sharedActors),
+			ComputedAt: now,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].NeighborID < out[j].NeighborID
+	})
+	if len(out) > keep {
+		out = out[:keep]
+	}
+	for i := range out {
+		out[i].Rank = i + 1
+	}
+	return out
+}
+
+// reason explains a match, e.g. "shares 2 genres and actor Tom Hanks"
+func reason(sharedGenres int, actors []string) string {
+	var parts []string
+	switch {
+	case sharedGenres == 1:
+		parts = append(parts, "1 genre")
+	case sharedGenres > 1:
+		parts = append(parts, fmt.Sprintf("%d genres", sharedGenres))
+	}
+
+	sort.Strings(actors)
+	switch {
+	case len(actors) == 1:
+		parts = append(parts, "actor "+actors[0])
+	case len(actors) == 2:
+		parts = append(parts, "actors "+actors[0]+" and "+actors[1])
+	case len(actors) > 2:
+		parts = append(parts, fmt.Sprintf("actors %s, %s and %d more", actors[0], actors[1], len(actors)-2))
+	}
+
+	s := []rune("shares " + strings.Join(parts, " and "))
+	if len(s) > 255 {
+		s = s[:255]
+	}
+	return string(s)
+}
+
+// Rebuild recomputes the neighbor list of every movie
+func (b *Builder) Rebuild(ctx context.Context) error {
+	db := b.DB.WithContext(ctx)
+	cat, err := loadCatalog(db)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var rows []models.MovieNeighbor
+	for id := range cat.movies {
+		rows = append(rows, cat.neighbors(id, b.Weights, b.Keep, now)...)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM movie_neighbors").Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&rows, 500).Error
+	})
+}
+
+// Refresh recomputes the lists of the given movies, plus every movie that
+// now shares a genre or actor with one of them or had one of them as a
+// neighbor, since their lists can change as well.
+func (b *Builder) Refresh(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	db := b.DB.WithContext(ctx)
+	cat, err := loadCatalog(db)
+	if err != nil {
+		return err
+	}
+
+	var referrers []uint
+	if err := db.Model(&models.MovieNeighbor{}).Where("neighbor_id IN ?", ids).
+		Distinct().Pluck("movie_id", &referrers).Error; err != nil {
+		return err
+	}
+	affected := map[uint]bool{}
+	for _, id := range append(ids, referrers...) {
+		affected[id] = true
+	}
+	for _, id := range ids {
+		for _, other := range cat.related(id) {
+			affected[other] = true
+		}
+	}
+
+	targets := make([]uint, 0, len(affected))
+	for id := range affected {
+		targets = append(targets, id)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+
+	now := time.Now()
+	var rows []models.MovieNeighbor
+	for _, id := range targets {
+		// deleted movies simply end up with no rows
+		rows = append(rows, cat.neighbors(id, b.Weights, b.Keep, now)...)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("movie_id IN ?", targets).Delete(&models.MovieNeighbor{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&rows, 500).Error
+	})
+}
+
+// Handle queues a refresh of the lists touched by one catalog event. The
+// bus is synchronous, so the refresh itself runs later in the Start loop,
+// not in the request that published the event.
+func (b *Builder) Handle(ctx context.Context, e events.Event) error {
+	switch e.Type {
+	case events.MovieSaved:
+		if e.RatingOnly {
+			// the rating weighs little; the periodic rebuild catches up
+			return nil
+		}
+		b.enqueue([]uint{e.ID})
+	case events.MovieDeleted:
+		b.enqueue([]uint{e.ID})
+	case events.ActorSaved:
+		// a renamed actor changes the reasons of their movies
+		var ids []uint
+		if err := b.DB.WithContext(ctx).Table("movie_actors").Where("actor_id = ?", e.ID).
+			Pluck("movie_id", &ids).Error; err != nil {
+			return err
+		}
+		b.enqueue(ids)
+	case events.ActorDeleted, events.GenreDeleted:
+		b.enqueue(e.MovieIDs)
+	}
+	return nil
+}
+
+func (b *Builder) enqueue(ids []uint) {
+	if len(ids) == 0 {
+		return
+	}
+	b.mu.Lock()
+	if b.pending == nil {
+		b.pending = map[uint]bool{}
+	}
+	for _, id := range ids {
+		b.pending[id] = true
+	}
+	b.mu.Unlock()
+	select {
+	case b.wake <- struct{}{}:
+	default: // a refresh is already due
+	}
+}
+
+// takePending empties the queue and returns the movies it held
+func (b *Builder) takePending() []uint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ids := make([]uint, 0, len(b.pending))
+	for id := range b.pending {
+		ids = append(ids, id)
+	}
+	b.pending = map[uint]bool{}
+	return ids
+}
+
+// Start rebuilds every list now and then every Interval until ctx is done.
+// In between it refreshes the movies queued by Handle, RefreshDelay after
+// the first event of a burst.
+func (b *Builder) Start(ctx context.Context) {
+	rebuild := func() {
+		if err := b.Rebuild(ctx); err != nil {
+			log.Printf("recommend: rebuild failed: %v", err)
+		}
+		if err := b.RebuildCooccurrence(ctx); err != nil {
+			log.Printf("recommend: co-occurrence rebuild failed: %v", err)
+		}
+	}
+	go func() {
+		rebuild()
+		t := time.NewTicker(b.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				rebuild()
+			case <-b.wake:
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(b.RefreshDelay):
+				}
+				if err := b.Refresh(ctx, b.takePending()); err != nil {
+					log.Printf("recommend: refresh failed: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,215 @@
+package recommend
+
+import (
+	"context"
+	"sort"
+
+	"movie-service/models"
+)
+
+// Seed is a movie the user has shown an opinion about. Weight is positive
+// for liked movies and negative for disliked ones.
+type Seed struct {
+	MovieID uint
+	Weight  float64
+	Source  string // "watchlist", "history" or "review"
+}
+
+// Pick is one personal recommendation
+type Pick struct {
+	MovieID uint
+	Score   float64
+	Reason  string
+
+	// the seed the reason points at, and the wording before its title
+	because uint
+	why     string
+}
+
+// how strongly each seed source speaks, used when a movie has several
+var sourceRank = map[string]int{"watchlist": 1, "history": 2, "review": 3}
+
+// ReviewWeight maps a 1-10 review score to a seed weight in [-1, 1]
+func ReviewWeight(score int) float64 {
+	return (float64(score) - 5.5) / 4.5
+}
+
+type contribution struct {
+	total float64
+	// seed that contributed most, used for the reason
+	best     uint
+	bestPart float64
+}
+
+func (c *contribution) add(seed uint, part float64) {
+	c.total += part
+	if part > c.bestPart {
+		c.best, c.bestPart = seed, part
+	}
+}
+
+// ForYou ranks movies for a user from their seeds. Content similarity comes
+// from the precomputed neighbor lists and the collaborative part from the
+// co-occurrence lists; each is normalized to [0, 1] and blended by CFWeight.
+// Seeds and movies in exclude are never returned. Without any positive
+// signal it falls back to what is trending this week.
+func (b *Builder) ForYou(ctx context.Context, seeds []Seed, exclude map[uint]bool, max int) ([]Pick, error) {
+	db := b.DB.WithContext(ctx)
+
+	weights := make(map[uint]float64, len(seeds))
+	sources := make(map[uint]string, len(seeds))
+	ids := make([]uint, 0, len(seeds))
+	for _, s := range seeds {
+		if _, ok := weights[s.MovieID]; !ok {
+			ids = append(ids, s.MovieID)
+		}
+		// a review says more than watching, watching more than a watchlist add
+		if sourceRank[s.Source] >= sourceRank[sources[s.MovieID]] {
+			weights[s.MovieID] = s.Weight
+			sources[s.MovieID] = s.Source
+		}
+	}
+
+	content := map[uint]*contribution{}
+	cf := map[uint]*contribution{}
+	if len(ids) > 0 {
+		var neighbors []models.MovieNeighbor
+		if err := db.Where("movie_id IN ?", ids).Find(&neighbors).Error; err != nil {
+			return nil, err
+		}
+		for _, n := range neighbors {
+			if content[n.NeighborID] == nil {
+				content[n.NeighborID] = &contribution{}
+			}
+			content[n.NeighborID].add(n.MovieID, weights[n.MovieID]*n.Score)
+		}
+
+		var pairs []models.MovieCooccurrence
+		if err := db.Where("movie_id IN ?", ids).Find(&pairs).Error; err != nil {
+			return nil, err
+		}
+		for _, p := range pairs {
+			if cf[p.OtherID] == nil {
+				cf[p.OtherID] = &contribution{}
+			}
+			cf[p.OtherID].add(p.MovieID, weights[p.MovieID]*p.Similarity)
+		}
+	}
+
+	maxContent, maxCF := maxTotal(content), maxTotal(cf)
+	candidates := map[uint]bool{}
+	for id := range content {
+		candidates[id] = true
+	}
+	for id := range cf {
+		candidates[id] = true
+	}
+
+	var picks []Pick
+	for id := range candidates {
+		if _, isSeed := weights[id]; isSeed || exclude[id] {
+			continue
+		}
+		var cs, cfs float64
+		c, f := content[id], cf[id]
+		if c != nil && maxContent > 0 {
+			cs = c.total / maxContent
+		}
+		if f != nil && maxCF > 0 {
+			cfs = f.total / maxCF
+		}
+		score := b.CFWeight*cfs + (1-b.CFWeight)*cs
+		if score <= 0 {
+			continue
+		}
+
+		pick := Pick{MovieID: id, Score: score}
+		if f != nil && b.CFWeight*cfs >= (1-b.CFWeight)*cs && f.bestPart > 0 {
+			pick.because, pick.why = f.best, "popular with viewers who liked "
+		} else if c != nil && c.bestPart > 0 {
+			pick.because, pick.why = c.best, "because you liked "
+			switch sources[c.best] {
+			case "watchlist":
+				pick.why = "because you saved "
+			case "history":
+				pick.why = "because you watched "
+			}
+		}
+		picks = append(picks, pick)
+	}
+
+	sort.Slice(picks, func(i, j int) bool {
+		if picks[i].Score != picks[j].Score {
+			return picks[i].Score > picks[j].Score
+		}
+		return picks[i].MovieID < picks[j].MovieID
+	})
+	if len(picks) > max {
+		picks = picks[:max]
+	}
+	if len(picks) == 0 {
+		return b.fallback(ctx, exclude, weights, max)
+	}
+	return picks, b.fillReasons(ctx, picks)
+}
+
+func maxTotal(m map[uint]*contribution) float64 {
+	max := 0.0
+	for _, c := range m {
+		if c.total > max {
+			max = c.total
+		}
+	}
+	return max
+}
+
+// fillReasons turns the seed of every pick into a readable reason
+func (b *Builder) fillReasons(ctx context.Context, picks []Pick) error {
+	ids := make([]uint, 0, len(picks))
+	for _, p := range picks {
+		ids = append(ids, p.because)
+	}
+	var movies []models.Movie
+	if err := b.DB.WithContext(ctx).Select("id", "title").Where("id IN ?", ids).Find(&movies).Error; err != nil {
+		return err
+	}
+	titles := make(map[uint]string, len(movies))
+	for _, m := range movies {
+		titles[m.ID] = m.Title
+	}
+	for i := range picks {
+		if t, ok := titles[picks[i].because]; ok {
+			picks[i].Reason = picks[i].why + t
+		} else {
+			picks[i].Reason = "matches your taste"
+		}
+	}
+	return nil
+}
+
+// fallback serves this week's trending movies, then the best rated ones
+func (b *Builder) fallback(ctx context.Context, exclude map[uint]bool, seeds map[uint]float64, max int) ([]Pick, error) {
+	skip := make([]uint, 0, len(exclude)+len(seeds))
+	for id := range exclude {
+		skip = append(skip, id)
+	}
+	for id := range seeds {
+		skip = append(skip, id)
+	}
+
+	q := b.DB.WithContext(ctx).Table("movies").
+		Joins("LEFT JOIN trending_scores t ON t.movie_id = movies.id AND t.period = ?", "week")
+	if len(skip) > 0 {
+		q = q.Where("movies.id NOT IN ?", skip)
+	}
+	var ids []uint
+	if err := q.Order("t.rank NULLS LAST, movies.rating DESC, movies.id").Limit(max).
+		Pluck("movies.id", &ids).Error; err != nil {
+		return nil, err
+	}
+	picks := make([]Pick, 0, len(ids))
+	for _, id := range ids {
+		picks = append(picks, Pick{MovieID: id, Reason: "popular right now"})
+	}
+	return picks, nil
+}
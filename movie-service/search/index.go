@@ -0,0 +1,93 @@
+package search
+
+import (
+	"context"
+
+	"movie-service/events"
+
+	"gorm.io/gorm"
+)
+
+// Index maintains the Postgres full-text search columns and answers queries.
+//
+// movies.search_vector is denormalized: it combines the title (weight A),
+// actor and genre names (B) and the synopsis (C), so it is recomputed from
+// catalog events. actors and genres only index their own name and use
+// generated columns that Postgres keeps up to date by itself.
+type Index struct {
+	DB *gorm.DB
+}
+
+var setupStatements = []string{
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+
+	`ALTER TABLE movies ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+	`ALTER TABLE actors ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('simple', coalesce(name, ''))) STORED`,
+	`ALTER TABLE genres ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('simple', coalesce(name, ''))) STORED`,
+
+	`CREATE INDEX IF NOT EXISTS idx_movies_search_vector ON movies USING GIN (search_vector)`,
+	`CREATE INDEX IF NOT EXISTS idx_actors_search_vector ON actors USING GIN (search_vector)`,
+	`CREATE INDEX IF NOT EXISTS idx_genres_search_vector ON genres USING GIN (search_vector)`,
+
+	// trigram indexes for typo-tolerant matching with the % operator
+	`CREATE INDEX IF NOT EXISTS idx_movies_title_trgm ON movies USING GIN (title gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_actors_name_trgm ON actors USING GIN (name gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_genres_name_trgm ON genres USING GIN (name gin_trgm_ops)`,
+}
+
+// Setup creates the search columns and indexes and fills search_vector for
+// movies that do not have one yet. Safe to call on every start.
+func (ix *Index) Setup(ctx context.Context) error {
+	db := ix.DB.WithContext(ctx)
+	for _, stmt := range setupStatements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return ix.reindex(ctx, "m.search_vector IS NULL")
+}
+
+// All vectors use the "simple" text search configuration: it does no
+// stemming, which suits the mixed Indonesian/English catalog.
+const movieVectorSQL = `UPDATE movies m SET search_vector =
+	setweight(to_tsvector('simple', coalesce(m.title, '')), 'A') ||
+	setweight(to_tsvector('simple', coalesce((
+		SELECT string_agg(a.name, ' ') FROM actors a
+		JOIN movie_actors ma ON ma.actor_id = a.id
+		WHERE ma.movie_id = m.id), '')), 'B') ||
+	setweight(to_tsvector('simple', coalesce((
+		SELECT string_agg(g.name, ' ') FROM genres g
+		JOIN movie_genres mg ON mg.genre_id = g.id
+		WHERE mg.movie_id = m.id), '')), 'B') ||
+	setweight(to_tsvector('simple', coalesce(m.synopsis, '')), 'C')
+WHERE `
+
+func (ix *Index) reindex(ctx context.Context, where string, args ...interface{}) error {
+	return ix.DB.WithContext(ctx).Exec(movieVectorSQL+where, args...).Error
+}
+
+// ReindexMovies recomputes search_vector for the given movies
+func (ix *Index) ReindexMovies(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return ix.reindex(ctx, "m.id IN ?", ids)
+}
+
+// Handle keeps movies.search_vector in sync with catalog changes
+func (ix *Index) Handle(ctx context.Context, e events.Event) error {
+	switch e.Type {
+	case events.MovieSaved:
+		return ix.ReindexMovies(ctx, []uint{e.ID})
+	case events.ActorSaved:
+		return ix.reindex(ctx, "m.id IN (SELECT movie_id FROM movie_actors WHERE actor_id = ?)", e.ID)
+	case events.GenreSaved:
+		return ix.reindex(ctx, "m.id IN (SELECT movie_id FROM movie_genres WHERE genre_id = ?)", e.ID)
+	case events.ActorDeleted, events.GenreDeleted:
+		return ix.ReindexMovies(ctx, e.MovieIDs)
+	}
+	// a deleted movie takes its own search_vector with it
+	return nil
+}
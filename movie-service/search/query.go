@@ -0,0 +1,135 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+type MovieHit struct {
+	ID             uint    `json:"id"`
+	Title          string  `json:"title"`
+	PosterMediaID  *uint   `json:"-"`
+	PosterURL      string  `json:"poster_url"`
+	ReleaseYear    int     `json:"release_year"`
+	Rating         float32 `json:"rating"`
+	IsPremium      bool    `json:"is_premium"`
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
+}
+
+type ActorHit struct {
+	ID           uint    `json:"id"`
+	Name         string  `json:"name"`
+	PhotoMediaID *uint   `json:"-"`
+	PhotoURL     string  `json:"photo_url"`
+	Rank         float64 `json:"rank"`
+	Highlight    string  `json:"highlight"`
+}
+
+type GenreHit struct {
+	ID        uint    `json:"id"`
+	Name      string  `json:"name"`
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+// Results groups hits by type; a type that was not requested stays nil
+type Results struct {
+	Movies []MovieHit `json:"movies"`
+	Actors []ActorHit `json:"actors"`
+	Genres []GenreHit `json:"genres"`
+}
+
+// Options selects which types to search and how many hits per type
+type Options struct {
+	Limit  int
+	Movies bool
+	Actors bool
+	Genres bool
+}
+
+const highlightOpts = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+
+// htmlEscaped wraps a text column in SQL that escapes it for HTML. Highlights
+// and snippets are HTML fragments, so the catalog text is escaped before
+// ts_headline adds the <mark> tags; markup in a title cannot reach clients.
+func htmlEscaped(col string) string {
+	return "replace(replace(replace(replace(replace(" + col +
+		`, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+// PrefixQuery turns free text into a to_tsquery expression where every word
+// must match as a prefix ("star wa" -> "star:* & wa:*"). Only letters and
+// digits survive, so the result is always a valid tsquery. It returns ""
+// when the text has no searchable words.
+func PrefixQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// Query ranks movies, actors and genres against text. A row matches when its
+// search_vector matches every word as a prefix, or when its title/name is
+// trigram-similar to the text (typo tolerance). Rank is ts_rank_cd plus the
+// trigram similarity.
+func (ix *Index) Query(ctx context.Context, text string, opts Options) (*Results, error) {
+	args := map[string]interface{}{
+		"tsq":   PrefixQuery(text),
+		"raw":   text,
+		"limit": opts.Limit,
+	}
+	db := ix.DB.WithContext(ctx)
+	res := &Results{}
+
+	if opts.Movies {
+		res.Movies = []MovieHit{}
+		err := db.Raw(`SELECT m.id, m.title, m.poster_media_id, m.release_year, m.rating, m.is_premium,
+				ts_rank_cd(m.search_vector, q.query) + similarity(m.title, @raw) AS rank,
+				ts_headline('simple', `+htmlEscaped("m.title")+`, q.query, '`+highlightOpts+`') AS title_highlight,
+				ts_headline('simple', `+htmlEscaped("coalesce(m.synopsis, '')")+`, q.query,
+					'StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2') AS snippet
+			FROM movies m, to_tsquery('simple', @tsq) AS q(query)
+			WHERE m.search_vector @@ q.query OR m.title % @raw
+			ORDER BY rank DESC, m.id
+			LIMIT @limit`, args).Scan(&res.Movies).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Actors {
+		res.Actors = []ActorHit{}
+		err := db.Raw(`SELECT a.id, a.name, a.photo_media_id,
+				ts_rank_cd(a.search_vector, q.query) + similarity(a.name, @raw) AS rank,
+				ts_headline('simple', `+htmlEscaped("a.name")+`, q.query, '`+highlightOpts+`') AS highlight
+			FROM actors a, to_tsquery('simple', @tsq) AS q(query)
+			WHERE a.search_vector @@ q.query OR a.name % @raw
+			ORDER BY rank DESC, a.id
+			LIMIT @limit`, args).Scan(&res.Actors).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Genres {
+		res.Genres = []GenreHit{}
+		err := db.Raw(`SELECT g.id, g.name,
+				ts_rank_cd(g.search_vector, q.query) + similarity(g.name, @raw) AS rank,
+				ts_headline('simple', `+htmlEscaped("g.name")+`, q.query, '`+highlightOpts+`') AS highlight
+			FROM genres g, to_tsquery('simple', @tsq) AS q(query)
+			WHERE g.search_vector @@ q.query OR g.name % @raw
+			ORDER BY rank DESC, g.id
+			LIMIT @limit`, args).Scan(&res.Genres).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
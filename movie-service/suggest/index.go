@@ -0,0 +1,221 @@
+package suggest
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	TypeMovie = "movie"
+	TypeActor = "actor"
+)
+
+// Entry is one completion candidate
+type Entry struct {
+	ID    uint    `json:"id"`
+	Label string  `json:"label"`
+	Type  string  `json:"type"`
+	Score float64 `json:"-"`
+}
+
+// Score ranks a movie (or an actor, from the movies they play in) by
+// popularity: log-scaled views so blockbusters don't drown everything, plus
+// the 0-10 rating.
+func Score(views int64, rating float64) float64 {
+	if views < 0 {
+		views = 0
+	}
+	return math.Log1p(float64(views)) + rating
+}
+
+type entryKey struct {
+	typ string
+	id  uint
+}
+
+// posting links one searchable token to an entry
+type posting struct {
+	token string
+	key   entryKey
+}
+
+// Index is an in-memory prefix index. Every label is indexed under each of
+// its word suffixes ("the dark knight", "dark knight", "knight"), kept in one
+// sorted slice, so a lookup is a binary search plus a scan of the matching
+// range. Updates insert/remove postings in place, no rebuild needed.
+type Index struct {
+	mu       sync.RWMutex
+	entries  map[entryKey]*Entry
+	postings []posting
+}
+
+func NewIndex() *Index {
+	return &Index{entries: make(map[entryKey]*Entry)}
+}
+
+// Normalize lowercases s and collapses everything that is not a letter or
+// digit into single spaces
+func Normalize(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+func tokens(label string) []string {
+	words := strings.Fields(Normalize(label))
+	out := make([]string, 0, len(words))
+	for i := range words {
+		out = append(out, strings.Join(words[i:], " "))
+	}
+	return out
+}
+
+// search returns the index of the first posting >= (token, key)
+func (ix *Index) search(token string, k entryKey) int {
+	return sort.Search(len(ix.postings), func(i int) bool {
+		p := ix.postings[i]
+		if p.token != token {
+			return p.token > token
+		}
+		if p.key.typ != k.typ {
+			return p.key.typ > k.typ
+		}
+		return p.key.id >= k.id
+	})
+}
+
+func (ix *Index) insertPosting(p posting) {
+	i := ix.search(p.token, p.key)
+	if i < len(ix.postings) && ix.postings[i] == p {
+		return
+	}
+	ix.postings = append(ix.postings, posting{})
+	copy(ix.postings[i+1:], ix.postings[i:])
+	ix.postings[i] = p
+}
+
+func (ix *Index) removePosting(p posting) {
+	i := ix.search(p.token, p.key)
+	if i < len(ix.postings) && ix.postings[i] == p {
+		ix.postings = append(ix.postings[:i], ix.postings[i+1:]...)
+	}
+}
+
+// Upsert adds e or replaces the entry with the same type and id
+func (ix *Index) Upsert(e Entry) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.upsertLocked(e)
+}
+
+func (ix *Index) upsertLocked(e Entry) {
+	k := entryKey{e.Type, e.ID}
+	if old, ok := ix.entries[k]; ok {
+		if old.Label == e.Label {
+			old.Score = e.Score
+			return
+		}
+		for _, t := range tokens(old.Label) {
+			ix.removePosting(posting{t, k})
+		}
+	}
+	ix.entries[k] = &e
+	for _, t := range tokens(e.Label) {
+		ix.insertPosting(posting{t, k})
+	}
+}
+
+// Remove drops an entry; unknown entries are ignored
+func (ix *Index) Remove(typ string, id uint) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	k := entryKey{typ, id}
+	old, ok := ix.entries[k]
+	if !ok {
+		return
+	}
+	for _, t := range tokens(old.Label) {
+		ix.removePosting(posting{t, k})
+	}
+	delete(ix.entries, k)
+}
+
+// Replace swaps the whole content of the index, used for full rebuilds
+func (ix *Index) Replace(entries []Entry) {
+	fresh := &Index{entries: make(map[entryKey]*Entry, len(entries))}
+	for i := range entries {
+		e := entries[i]
+		k := entryKey{e.Type, e.ID}
+		fresh.entries[k] = &e
+		for _, t := range tokens(e.Label) {
+			fresh.postings = append(fresh.postings, posting{t, k})
+		}
+	}
+	sort.Slice(fresh.postings, func(i, j int) bool {
+		a, b := fresh.postings[i], fresh.postings[j]
+		if a.token != b.token {
+			return a.token < b.token
+		}
+		if a.key.typ != b.key.typ {
+			return a.key.typ < b.key.typ
+		}
+		return a.key.id < b.key.id
+	})
+
+	ix.mu.Lock()
+	ix.entries, ix.postings = fresh.entries, fresh.postings
+	ix.mu.Unlock()
+}
+
+// Lookup returns up to n entries whose label has a word sequence starting
+// with prefix, best score first. types filters by entry type (nil = all).
+func (ix *Index) Lookup(prefix string, n int, types map[string]bool) []Entry {
+	prefix = Normalize(prefix)
+	if prefix == "" || n <= 0 {
+		return []Entry{}
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	seen := make(map[entryKey]bool)
+	var hits []*Entry
+	start := sort.Search(len(ix.postings), func(i int) bool { return ix.postings[i].token >= prefix })
+	for i := start; i < len(ix.postings) && strings.HasPrefix(ix.postings[i].token, prefix); i++ {
+		k := ix.postings[i].key
+		if seen[k] || (types != nil && !types[k.typ]) {
+			continue
+		}
+		seen[k] = true
+		hits = append(hits, ix.entries[k])
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Label != hits[j].Label {
+			return hits[i].Label < hits[j].Label
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if len(hits) > n {
+		hits = hits[:n]
+	}
+	out := make([]Entry, len(hits))
+	for i, h := range hits {
+		out[i] = *h
+	}
+	return out
+}
+
+// Len returns the number of entries
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.entries)
+}
@@ -0,0 +1,124 @@
+package suggest
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"movie-service/events"
+
+	"gorm.io/gorm"
+)
+
+// Loader fills an Index from the database and keeps it current from catalog
+// events. A periodic rebuild picks up score drift (views, ratings) that does
+// not go through the catalog controllers.
+type Loader struct {
+	DB    *gorm.DB
+	Index *Index
+}
+
+type scoredRow struct {
+	ID     uint
+	Label  string
+	Views  int64
+	Rating float64
+}
+
+const movieRowsSQL = `SELECT m.id, m.title AS label, m.views, m.rating FROM movies m`
+
+// an actor is as popular as the movies they play in
+const actorRowsSQL = `SELECT a.id, a.name AS label,
+		COALESCE(SUM(m.views), 0) AS views, COALESCE(AVG(m.rating), 0) AS rating
+	FROM actors a
+	LEFT JOIN movie_actors ma ON ma.actor_id = a.id
+	LEFT JOIN movies m ON m.id = ma.movie_id`
+
+func toEntries(typ string, rows []scoredRow) []Entry {
+	out := make([]Entry, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, Entry{ID: r.ID, Label: r.Label, Type: typ, Score: Score(r.Views, r.Rating)})
+	}
+	return out
+}
+
+// Rebuild reloads every movie and actor
+func (l *Loader) Rebuild(ctx context.Context) error {
+	db := l.DB.WithContext(ctx)
+	var movies, actors []scoredRow
+	if err := db.Raw(movieRowsSQL).Scan(&movies).Error; err != nil {
+		return err
+	}
+	if err := db.Raw(actorRowsSQL + ` GROUP BY a.id, a.name`).Scan(&actors).Error; err != nil {
+		return err
+	}
+	l.Index.Replace(append(toEntries(TypeMovie, movies), toEntries(TypeActor, actors)...))
+	return nil
+}
+
+func (l *Loader) refreshMovies(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var rows []scoredRow
+	if err := l.DB.WithContext(ctx).Raw(movieRowsSQL+` WHERE m.id IN ?`, ids).Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, e := range toEntries(TypeMovie, rows) {
+		l.Index.Upsert(e)
+	}
+	return nil
+}
+
+func (l *Loader) refreshActors(ctx context.Context, where string, args ...interface{}) error {
+	var rows []scoredRow
+	if err := l.DB.WithContext(ctx).Raw(actorRowsSQL+` WHERE `+where+` GROUP BY a.id, a.name`, args...).
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, e := range toEntries(TypeActor, rows) {
+		l.Index.Upsert(e)
+	}
+	return nil
+}
+
+// Handle applies one catalog event to the index
+func (l *Loader) Handle(ctx context.Context, e events.Event) error {
+	switch e.Type {
+	case events.MovieSaved:
+		if err := l.refreshMovies(ctx, []uint{e.ID}); err != nil {
+			return err
+		}
+		// the movie's cast inherits its popularity
+		return l.refreshActors(ctx, "a.id IN (SELECT actor_id FROM movie_actors WHERE movie_id = ?)", e.ID)
+	case events.MovieDeleted:
+		l.Index.Remove(TypeMovie, e.ID)
+	case events.ActorSaved:
+		return l.refreshActors(ctx, "a.id = ?", e.ID)
+	case events.ActorDeleted:
+		l.Index.Remove(TypeActor, e.ID)
+	}
+	return nil
+}
+
+// Start rebuilds the index now and then every interval until ctx is done
+func (l *Loader) Start(ctx context.Context, interval time.Duration) error {
+	if err := l.Rebuild(ctx); err != nil {
+		return err
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := l.Rebuild(ctx); err != nil {
+					log.Printf("suggest: rebuild failed: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
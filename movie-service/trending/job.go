@@ -0,0 +1,191 @@
+package trending
+
+import (
+	"context"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"movie-service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Window is a trending time range. Activity older than Span is ignored and
+// the weight of an event halves every HalfLife.
+type Window struct {
+	Name     string
+	Span     time.Duration
+	HalfLife time.Duration
+}
+
+var Windows = []Window{
+	{Name: "day", Span: 24 * time.Hour, HalfLife: 6 * time.Hour},
+	{Name: "week", Span: 7 * 24 * time.Hour, HalfLife: 36 * time.Hour},
+	{Name: "month", Span: 30 * 24 * time.Hour, HalfLife: 7 * 24 * time.Hour},
+}
+
+// FindWindow returns the window with the given name
+func FindWindow(name string) (Window, bool) {
+	for _, w := range Windows {
+		if w.Name == name {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
+
+// Weights is how many points one event of each kind is worth
+type Weights struct {
+	View      float64
+	Watchlist float64
+	Review    float64
+}
+
+// how many movies are kept per window
+const keepTop = 500
+
+// Job periodically recomputes the trending ranking of every window from
+// hourly activity buckets: views (movie_activities), watchlist and list adds
+// (user-service's list_items table in the shared database) and reviews.
+type Job struct {
+	DB       *gorm.DB
+	Interval time.Duration
+	Weights  Weights
+}
+
+func NewJob(db *gorm.DB) *Job {
+	interval := 10 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("TRENDING_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+	return &Job{
+		DB:       db,
+		Interval: interval,
+		Weights: Weights{
+			View:      floatEnv("TRENDING_WEIGHT_VIEW", 1),
+			Watchlist: floatEnv("TRENDING_WEIGHT_WATCHLIST", 5),
+			Review:    floatEnv("TRENDING_WEIGHT_REVIEW", 10),
+		},
+	}
+}
+
+func floatEnv(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// RecordViews adds flushed view counts to the current hour bucket. It is
+// hooked into views.Counter.OnFlush.
+func (j *Job) RecordViews(ctx context.Context, at time.Time, counts map[uint]int64) error {
+	bucket := at.UTC().Truncate(time.Hour)
+	rows := make([]models.MovieActivity, 0, len(counts))
+	for id, n := range counts {
+		rows = append(rows, models.MovieActivity{MovieID: id, Bucket: bucket, Views: n})
+	}
+	return j.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "movie_id"}, {Name: "bucket"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"views": gorm.Expr("movie_activities.views + EXCLUDED.views")}),
+	}).Create(&rows).Error
+}
+
+// activitySQL sums the decayed points of every event since @since. Each
+// event counts at the start of its hour bucket.
+const activitySQL = `WITH activity AS (
+		SELECT movie_id, bucket, views * CAST(@wv AS float8) AS pts FROM movie_activities WHERE bucket >= @since
+		UNION ALL
+		SELECT movie_id, date_trunc('hour', created_at), CAST(@wr AS float8) FROM reviews WHERE created_at >= @since
+		%WATCHLIST%
+	)
+	SELECT a.movie_id, SUM(a.pts * exp(-CAST(@lambda AS float8) * extract(epoch FROM (@now - a.bucket))::float8)) AS score
+	FROM activity a
+	JOIN movies m ON m.id = a.movie_id
+	GROUP BY a.movie_id
+	ORDER BY score DESC, a.movie_id
+	LIMIT @keep`
+
+const watchlistSQL = `UNION ALL
+		SELECT movie_id, date_trunc('hour', created_at), CAST(@ww AS float8) FROM list_items WHERE created_at >= @since`
+
+// Recompute rebuilds the ranking of every window
+func (j *Job) Recompute(ctx context.Context) error {
+	now := time.Now().UTC()
+	db := j.DB.WithContext(ctx)
+
+	// list_items belongs to user-service; it may not exist yet
+	sql := activitySQL
+	if db.Migrator().HasTable("list_items") {
+		sql = strings.Replace(sql, "%WATCHLIST%", watchlistSQL, 1)
+	} else {
+		sql = strings.Replace(sql, "%WATCHLIST%", "", 1)
+	}
+
+	for _, w := range Windows {
+		var rows []struct {
+			MovieID uint
+			Score   float64
+		}
+		err := db.Raw(sql, map[string]interface{}{
+			"since":  now.Add(-w.Span),
+			"now":    now,
+			"lambda": math.Ln2 / w.HalfLife.Seconds(),
+			"wv":     j.Weights.View,
+			"ww":     j.Weights.Watchlist,
+			"wr":     j.Weights.Review,
+			"keep":   keepTop,
+		}).Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		scores := make([]models.TrendingScore, 0, len(rows))
+		for i, r := range rows {
+			scores = append(scores, models.TrendingScore{
+				Period: w.Name, MovieID: r.MovieID, Rank: i + 1, Score: r.Score, ComputedAt: now,
+			})
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("period = ?", w.Name).Delete(&models.TrendingScore{}).Error; err != nil {
+				return err
+			}
+			if len(scores) == 0 {
+				return nil
+			}
+			return tx.CreateInBatches(&scores, 200).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// buckets older than the longest window are no longer needed
+	oldest := Windows[len(Windows)-1].Span
+	return db.Where("bucket < ?", now.Add(-oldest)).Delete(&models.MovieActivity{}).Error
+}
+
+// Start recomputes the rankings now and then every Interval until ctx is done
+func (j *Job) Start(ctx context.Context) {
+	go func() {
+		if err := j.Recompute(ctx); err != nil {
+			log.Printf("trending: recompute failed: %v", err)
+		}
+		t := time.NewTicker(j.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := j.Recompute(ctx); err != nil {
+					log.Printf("trending: recompute failed: %v", err)
+				}
+			}
+		}
+	}()
+}
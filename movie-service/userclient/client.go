@@ -0,0 +1,127 @@
+package userclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"movie-service/events"
+)
+
+// Client calls user-service, either on behalf of a user by forwarding their
+// Authorization header like subscription-service does, or as movie-service
+// itself with the shared INTERNAL_API_TOKEN.
+type Client struct {
+	BaseURL       string
+	InternalToken string
+	HTTP          *http.Client
+}
+
+func NewFromEnv() *Client {
+	base := os.Getenv("USER_SERVICE_URL")
+	if base == "" {
+		base = "http://localhost:8001"
+	}
+	return &Client{
+		BaseURL:       strings.TrimRight(base, "/"),
+		InternalToken: os.Getenv("INTERNAL_API_TOKEN"),
+		HTTP:          &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+type WatchlistItem struct {
+	MovieID uint      `json:"movie_id"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// HistoryItem sums up the viewing sessions of one movie
+type HistoryItem struct {
+	MovieID       uint      `json:"movie_id"`
+	Finished      bool      `json:"finished"`
+	LastWatchedAt time.Time `json:"last_watched_at"`
+}
+
+// Signals is what user-service knows about a user's taste
+type Signals struct {
+	Watchlist []WatchlistItem `json:"watchlist"`
+	History   []HistoryItem   `json:"history"`
+}
+
+// Signals fetches GET /profile/signals for the user the token belongs to
+func (c *Client) Signals(ctx context.Context, authHeader string) (*Signals, error) {
+	var out Signals
+	if err := c.get(ctx, "/profile/signals", authHeader, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WatchlistIDs returns which of ids are on the default list of the user
+// the token belongs to (GET /profile/watchlist/ids, at most 100 ids)
+func (c *Client) WatchlistIDs(ctx context.Context, authHeader string, ids []uint) ([]uint, error) {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	var out struct {
+		MovieIDs []uint `json:"movie_ids"`
+	}
+	path := "/profile/watchlist/ids?movie_ids=" + strings.Join(parts, ",")
+	if err := c.get(ctx, path, authHeader, &out); err != nil {
+		return nil, err
+	}
+	return out.MovieIDs, nil
+}
+
+// ForgetMovie removes a deleted movie from every list, watch progress and history
+func (c *Client) ForgetMovie(ctx context.Context, movieID uint) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete,
+		fmt.Sprintf("%s/internal/movies/%d", c.BaseURL, movieID), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Internal-Token", c.InternalToken)
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("user-service forget movie %d: status %d", movieID, resp.StatusCode)
+	}
+	return nil
+}
+
+// Handle cleans up user-service data of deleted movies. A failed call only
+// leaves stale entries behind, which user-service prunes when it next
+// hydrates the watchlist.
+func (c *Client) Handle(ctx context.Context, e events.Event) error {
+	if e.Type != events.MovieDeleted {
+		return nil
+	}
+	return c.ForgetMovie(ctx, e.ID)
+}
+
+func (c *Client) get(ctx context.Context, path, authHeader string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("user-service %s: status %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,193 @@
+package views
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Counter counts movie views in memory and adds them to movies.views in
+// batches, so a hot title costs one UPDATE per flush instead of one per view.
+//
+// A viewer (user id or anonymous session) is counted at most once per movie
+// within Window. Anonymous session ids are made up by the client, so at most
+// SessionsPerIP of them are counted per IP and movie within Window. The
+// dedup state is per process; behind several replicas a viewer can be
+// counted once per replica and window.
+type Counter struct {
+	DB            *gorm.DB
+	Window        time.Duration
+	FlushEvery    time.Duration
+	SessionsPerIP int
+
+	// OnFlush, if set, receives every batch after it was written to
+	// movies.views. Its errors are logged; the batch is not retried.
+	OnFlush func(ctx context.Context, at time.Time, counts map[uint]int64) error
+
+	mu      sync.Mutex
+	seen    map[string]time.Time
+	perIP   map[string]ipSessions
+	pending map[uint]int64
+}
+
+// ipSessions counts the anonymous sessions of one IP and movie counted since
+// the first of them
+type ipSessions struct {
+	n     int
+	since time.Time
+}
+
+func NewCounter(db *gorm.DB) *Counter {
+	perIP := 5
+	if n, err := strconv.Atoi(os.Getenv("VIEW_SESSIONS_PER_IP")); err == nil && n > 0 {
+		perIP = n
+	}
+	return &Counter{
+		DB:            db,
+		Window:        durationEnv("VIEW_DEDUP_WINDOW", 30*time.Minute),
+		FlushEvery:    durationEnv("VIEW_FLUSH_INTERVAL", 10*time.Second),
+		SessionsPerIP: perIP,
+		seen:          make(map[string]time.Time),
+		perIP:         make(map[string]ipSessions),
+		pending:       make(map[uint]int64),
+	}
+}
+
+func durationEnv(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+// Record counts a view of movieID by viewer unless the same viewer was
+// already counted for that movie within Window. ip is set for anonymous
+// viewers and caps their sessions per IP. It reports whether the view was
+// counted.
+func (c *Counter) Record(movieID uint, viewer, ip string, now time.Time) bool {
+	key := fmt.Sprintf("%d|%s", movieID, viewer)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last, ok := c.seen[key]; ok && now.Sub(last) < c.Window {
+		return false
+	}
+	if ip != "" {
+		ipKey := fmt.Sprintf("%d|%s", movieID, ip)
+		st := c.perIP[ipKey]
+		if now.Sub(st.since) >= c.Window {
+			st = ipSessions{since: now}
+		}
+		if st.n >= c.SessionsPerIP {
+			return false
+		}
+		st.n++
+		c.perIP[ipKey] = st
+	}
+	c.seen[key] = now
+	c.pending[movieID]++
+	return true
+}
+
+// Flush writes the pending counts in one statement. On failure the counts
+// are put back and retried on the next flush.
+func (c *Counter) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = make(map[uint]int64)
+	c.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// ids in ascending order so concurrent flushes from several replicas
+	// lock rows in the same order
+	ids := make([]uint, 0, len(batch))
+	for id := range batch {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	values := make([]string, 0, len(ids))
+	args := make([]interface{}, 0, len(ids)*2)
+	for _, id := range ids {
+		values = append(values, "(?::bigint, ?::bigint)")
+		args = append(args, id, batch[id])
+	}
+	sql := `UPDATE movies SET views = movies.views + v.n
+		FROM (VALUES ` + strings.Join(values, ", ") + `) AS v(id, n)
+		WHERE movies.id = v.id`
+
+	if err := c.DB.WithContext(ctx).Exec(sql, args...).Error; err != nil {
+		c.restore(batch)
+		return err
+	}
+	if c.OnFlush != nil {
+		if err := c.OnFlush(ctx, time.Now(), batch); err != nil {
+			log.Printf("views: flush hook failed: %v", err)
+		}
+	}
+	return nil
+}
+
+func (c *Counter) restore(batch map[uint]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, n := range batch {
+		c.pending[id] += n
+	}
+}
+
+// prune forgets viewers whose dedup window has passed
+func (c *Counter) prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, t := range c.seen {
+		if now.Sub(t) >= c.Window {
+			delete(c.seen, k)
+		}
+	}
+	for k, st := range c.perIP {
+		if now.Sub(st.since) >= c.Window {
+			delete(c.perIP, k)
+		}
+	}
+}
+
+// Start flushes every FlushEvery until ctx is done, then flushes once more
+// with a fresh context so buffered views survive a graceful shutdown.
+// The returned channel is closed after that final flush.
+func (c *Counter) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(c.FlushEvery)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				if err := c.Flush(flushCtx); err != nil {
+					log.Printf("views: final flush failed: %v", err)
+				}
+				cancel()
+				return
+			case now := <-t.C:
+				if err := c.Flush(ctx); err != nil {
+					log.Printf("views: flush failed: %v", err)
+				}
+				c.prune(now)
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,141 @@
+// Package auth verifies the access tokens user-service issues. It is shared
+// by all services, so they agree on the claims, the accepted algorithms and
+// what a revoked token is.
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMissingToken = errors.New("missing token")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrRevokedToken = errors.New("token revoked")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+// Permissions the services check with RequirePermission; user-service
+// decides which roles grant them
+const (
+	PermCatalogWrite = "catalog:write" // create, update and delete movies, genres, actors and media
+	PermRolesManage  = "roles:manage"  // grant and revoke roles
+)
+
+// Claims are the claims of an access token. ID (jti) names the token on the
+// denylist, SessionID the login session it belongs to. Roles and
+// Permissions are fixed when the token is issued, so a role change shows
+// up with the next refresh.
+type Claims struct {
+	UserID      uint     `json:"user_id"`
+	Email       string   `json:"email"`
+	SessionID   uint     `json:"sid"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Can reports whether the token grants perm
+func (c *Claims) Can(perm string) bool {
+	for _, p := range c.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Issuer is the iss of every access token (JWT_ISSUER)
+func Issuer() string {
+	if v := os.Getenv("JWT_ISSUER"); v != "" {
+		return v
+	}
+	return "user-service"
+}
+
+// Audience is the aud of every access token (JWT_AUDIENCE)
+func Audience() string {
+	if v := os.Getenv("JWT_AUDIENCE"); v != "" {
+		return v
+	}
+	return "movie-rest"
+}
+
+// RevokedFunc reports whether the token with the given jti was revoked
+type RevokedFunc func(ctx context.Context, jti string) (bool, error)
+
+// SQLDenylist reads user-service's revoked_tokens table, which every service
+// can reach in the shared database
+func SQLDenylist(db *gorm.DB) RevokedFunc {
+	return func(ctx context.Context, jti string) (bool, error) {
+		var revoked bool
+		err := db.WithContext(ctx).
+			Raw("SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)", jti).
+			Scan(&revoked).Error
+		return revoked, err
+	}
+}
+
+// Verifier checks access tokens: signature, algorithm, iss, aud, exp, and
+// the denylist
+type Verifier struct {
+	Keyfunc    jwt.Keyfunc
+	Issuer     string
+	Audience   string
+	Algorithms []string
+	Revoked    RevokedFunc // nil skips the denylist
+}
+
+// NewVerifier accepts EdDSA and RS256 tokens of Issuer() for Audience().
+// HS256 and "none" are never accepted.
+func NewVerifier(keyfunc jwt.Keyfunc, revoked RevokedFunc) *Verifier {
+	return &Verifier{
+		Keyfunc:    keyfunc,
+		Issuer:     Issuer(),
+		Audience:   Audience(),
+		Algorithms: []string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()},
+		Revoked:    revoked,
+	}
+}
+
+// Parse checks the token itself. Tokens without a user or a jti, which
+// could not be revoked, are invalid.
+func (v *Verifier) Parse(tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenStr, claims, v.Keyfunc,
+		jwt.WithValidMethods(v.Algorithms),
+		jwt.WithIssuer(v.Issuer),
+		jwt.WithAudience(v.Audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.UserID == 0 || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// Verify parses the token and consults the denylist. Errors other than
+// ErrInvalidToken and ErrRevokedToken come from the denylist lookup.
+func (v *Verifier) Verify(ctx context.Context, tokenStr string) (*Claims, error) {
+	claims, err := v.Parse(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if v.Revoked != nil {
+		revoked, err := v.Revoked(ctx, claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrRevokedToken
+		}
+	}
+	return claims, nil
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testKid = "test-key"
+
+type testKeys struct {
+	pub  ed25519.PublicKey
+	priv ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKeys{pub: pub, priv: priv}
+}
+
+func (k testKeys) keyfunc(t *jwt.Token) (interface{}, error) {
+	if kid, _ := t.Header["kid"].(string); kid != testKid {
+		return nil, ErrUnknownKey
+	}
+	return k.pub, nil
+}
+
+// validClaims are the claims of a token the verifier accepts
+func validClaims() Claims {
+	now := time.Now()
+	return Claims{
+		UserID:      7,
+		Email:       "user@example.com",
+		SessionID:   3,
+		Permissions: []string{PermCatalogWrite},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti-1",
+			Issuer:    Issuer(),
+			Audience:  jwt.ClaimStrings{Audience()},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+}
+
+func (k testKeys) sign(t *testing.T, claims Claims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	tok.Header["kid"] = testKid
+	s, err := tok.SignedString(k.priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func denylist(jtis ...string) RevokedFunc {
+	return func(ctx context.Context, jti string) (bool, error) {
+		for _, j := range jtis {
+			if j == jti {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+func TestVerifierVerify(t *testing.T) {
+	keys := newTestKeys(t)
+
+	hs256 := func(t *testing.T) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+		tok.Header["kid"] = testKid
+		s, err := tok.SignedString([]byte(keys.pub)) // the public key as HMAC secret
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	none := func(t *testing.T) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims())
+		tok.Header["kid"] = testKid
+		s, err := tok.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	with := func(change func(*Claims)) func(*testing.T) string {
+		return func(t *testing.T) string {
+			c := validClaims()
+			change(&c)
+			return keys.sign(t, c)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		token   func(*testing.T) string
+		revoked RevokedFunc
+		wantErr error
+	}{
+		{name: "valid", token: with(func(*Claims) {})},
+		{name: "alg HS256", token: hs256, wantErr: ErrInvalidToken},
+		{name: "alg none", token: none, wantErr: ErrInvalidToken},
+		{name: "wrong issuer", token: with(func(c *Claims) { c.Issuer = "someone-else" }), wantErr: ErrInvalidToken},
+		{name: "wrong audience", token: with(func(c *Claims) { c.Audience = jwt.ClaimStrings{"other"} }), wantErr: ErrInvalidToken},
+		{name: "expired", token: with(func(c *Claims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}), wantErr: ErrInvalidToken},
+		{name: "no expiry", token: with(func(c *Claims) { c.ExpiresAt = nil }), wantErr: ErrInvalidToken},
+		{name: "missing jti", token: with(func(c *Claims) { c.ID = "" }), wantErr: ErrInvalidToken},
+		{name: "missing user_id", token: with(func(c *Claims) { c.UserID = 0 }), wantErr: ErrInvalidToken},
+		{name: "unknown kid", token: func(t *testing.T) string {
+			tok := jwt.NewWithClaims(jwt.SigningMethodEdDSA, validClaims())
+			tok.Header["kid"] = "other"
+			s, _ := tok.SignedString(keys.priv)
+			return s
+		}, wantErr: ErrInvalidToken},
+		{name: "revoked jti", token: with(func(*Claims) {}), revoked: denylist("jti-1"), wantErr: ErrRevokedToken},
+		{name: "other jti revoked", token: with(func(*Claims) {}), revoked: denylist("jti-2")},
+		{name: "denylist failing", token: with(func(*Claims) {}), revoked: func(context.Context, string) (bool, error) {
+			return false, errors.New("db down")
+		}, wantErr: errAny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVerifier(keys.keyfunc, tt.revoked)
+			claims, err := v.Verify(context.Background(), tt.token(t))
+			switch {
+			case tt.wantErr == nil:
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if claims.UserID != 7 || claims.SessionID != 3 || claims.ID != "jti-1" {
+					t.Fatalf("claims = %+v", claims)
+				}
+			case tt.wantErr == errAny:
+				if err == nil || errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrRevokedToken) {
+					t.Fatalf("err = %v, want a lookup error", err)
+				}
+			default:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+// errAny stands for an error that is neither invalid nor revoked
+var errAny = errors.New("any")
+
+func TestClaimsCan(t *testing.T) {
+	c := validClaims()
+	if !c.Can(PermCatalogWrite) {
+		t.Error("Can(catalog:write) = false")
+	}
+	if c.Can(PermRolesManage) {
+		t.Error("Can(roles:manage) = true")
+	}
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// an unknown kid triggers a refetch at most this often, so tokens with made
+// up kids cannot hammer user-service
+const jwksMinRefetch = 30 * time.Second
+
+// JWKS caches the public keys user-service publishes at
+// /.well-known/jwks.json. Keys are refetched every TTL, and early when a
+// token names a kid the cache does not know yet (a freshly rotated key).
+type JWKS struct {
+	URL  string
+	TTL  time.Duration
+	HTTP *http.Client
+
+	mu       sync.Mutex
+	keys     map[string]interface{}
+	fetched  time.Time
+	inflight chan struct{} // closed when the running fetch is done
+}
+
+func NewJWKSFromEnv() *JWKS {
+	url := os.Getenv("JWKS_URL")
+	if url == "" {
+		base := os.Getenv("USER_SERVICE_URL")
+		if base == "" {
+			base = "http://localhost:8001"
+		}
+		url = strings.TrimRight(base, "/") + "/.well-known/jwks.json"
+	}
+	ttl := 10 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("JWKS_CACHE_TTL")); err == nil && d > 0 {
+		ttl = d
+	}
+	return &JWKS{URL: url, TTL: ttl, HTTP: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Keyfunc finds the public key of a token by its kid, for jwt.Parse. The
+// HTTP fetch runs without the lock held: tokens with a known kid keep
+// verifying with the cached keys while it runs, only an unknown kid waits
+// for its result.
+func (j *JWKS) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	j.mu.Lock()
+	key, ok := j.keys[kid]
+	since := time.Since(j.fetched)
+	wait := j.inflight
+	if wait == nil && (since > j.TTL || (!ok && since > jwksMinRefetch)) {
+		// also marked on failure, so a down user-service is not asked on
+		// every request
+		j.fetched = time.Now()
+		wait = make(chan struct{})
+		j.inflight = wait
+		j.mu.Unlock()
+		j.refresh(wait)
+		j.mu.Lock()
+	}
+	j.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+	if wait != nil {
+		<-wait
+		j.mu.Lock()
+		key, ok = j.keys[kid]
+		j.mu.Unlock()
+	}
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// refresh fetches the keys and ends the fetch marked by done
+func (j *JWKS) refresh(done chan struct{}) {
+	keys, err := j.fetch(context.Background())
+	j.mu.Lock()
+	if err != nil {
+		// keep verifying with the keys we have
+		log.Printf("jwks: fetching %s failed: %v", j.URL, err)
+	} else {
+		j.keys = keys
+	}
+	j.inflight = nil
+	j.mu.Unlock()
+	close(done)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+}
+
+// fetch downloads the key set; it touches no cached state
+func (j *JWKS) fetch(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			log.Printf("jwks: skipping key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid x")
+		}
+		return ed25519.PublicKey(x), nil
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.New("invalid n")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid e")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
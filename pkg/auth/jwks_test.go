@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksServer publishes the test key under the kids in *kids and counts the
+// fetches
+type jwksServer struct {
+	*httptest.Server
+	fetches atomic.Int32
+	mu      sync.Mutex
+	kids    []string
+	delay   time.Duration
+}
+
+func newJWKSServer(t *testing.T, keys testKeys, kids ...string) *jwksServer {
+	s := &jwksServer{kids: kids}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		time.Sleep(s.delay)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		set := struct {
+			Keys []jwk `json:"keys"`
+		}{}
+		for _, kid := range s.kids {
+			set.Keys = append(set.Keys, jwk{
+				Kty: "OKP", Crv: "Ed25519", Use: "sig", Kid: kid,
+				X: base64.RawURLEncoding.EncodeToString(keys.pub),
+			})
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) publish(kids ...string) {
+	s.mu.Lock()
+	s.kids = kids
+	s.mu.Unlock()
+}
+
+func tokenWithKid(kid string) *jwt.Token {
+	return &jwt.Token{Header: map[string]interface{}{"kid": kid}}
+}
+
+func TestJWKSKeyfunc(t *testing.T) {
+	keys := newTestKeys(t)
+
+	tests := []struct {
+		name        string
+		published   []string
+		fetchedAgo  time.Duration // zero: nothing cached yet
+		rotate      []string      // published after the cache was filled
+		kid         string
+		wantErr     error
+		wantFetches int32
+	}{
+		{name: "first use fetches", published: []string{"a"}, kid: "a", wantFetches: 1},
+		{name: "cached kid, no fetch", published: []string{"a"}, fetchedAgo: time.Minute, kid: "a"},
+		{name: "cache past TTL refetches", published: []string{"a"}, fetchedAgo: time.Hour, kid: "a", wantFetches: 1},
+		{name: "unknown kid refetches", published: []string{"a"}, fetchedAgo: time.Minute, rotate: []string{"a", "b"},
+			kid: "b", wantFetches: 1},
+		{name: "unknown kid within throttle", published: []string{"a"}, fetchedAgo: time.Second, rotate: []string{"a", "b"},
+			kid: "b", wantErr: ErrUnknownKey},
+		{name: "unknown kid nowhere", published: []string{"a"}, fetchedAgo: time.Minute, kid: "z",
+			wantErr: ErrUnknownKey, wantFetches: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newJWKSServer(t, keys, tt.published...)
+			j := &JWKS{URL: srv.URL, TTL: 10 * time.Minute, HTTP: srv.Client()}
+			if tt.fetchedAgo > 0 {
+				if _, err := j.Keyfunc(tokenWithKid(tt.published[0])); err != nil {
+					t.Fatal(err)
+				}
+				j.fetched = time.Now().Add(-tt.fetchedAgo)
+				srv.fetches.Store(0)
+			}
+			if tt.rotate != nil {
+				srv.publish(tt.rotate...)
+			}
+
+			key, err := j.Keyfunc(tokenWithKid(tt.kid))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || key == nil {
+				t.Fatalf("Keyfunc: %v", err)
+			}
+			if got := srv.fetches.Load(); got != tt.wantFetches {
+				t.Fatalf("fetches = %d, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
+
+// A slow JWKS endpoint must not hold up tokens whose key is cached
+func TestJWKSKeyfuncFetchOutsideLock(t *testing.T) {
+	keys := newTestKeys(t)
+	srv := newJWKSServer(t, keys, "a")
+	j := &JWKS{URL: srv.URL, TTL: 10 * time.Minute, HTTP: srv.Client()}
+	if _, err := j.Keyfunc(tokenWithKid("a")); err != nil {
+		t.Fatal(err)
+	}
+	j.fetched = time.Now().Add(-time.Minute)
+	srv.delay = 500 * time.Millisecond
+
+	go j.Keyfunc(tokenWithKid("unknown")) // starts the slow refetch
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := j.Keyfunc(tokenWithKid("a")); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatalf("cached kid waited %s for the fetch", d)
+	}
+}
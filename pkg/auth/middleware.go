@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the context key the verified claims are stored under
+const claimsKey = "auth.claims"
+
+func bearer(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	return token, token != ""
+}
+
+// Required rejects requests without a valid, unrevoked access token and
+// stores the claims for UserID, SessionID and TokenID
+func (v *Verifier) Required() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearer(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrMissingToken.Error()})
+			return
+		}
+		claims, err := v.Verify(c.Request.Context(), token)
+		if err != nil {
+			if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrRevokedToken) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check token"})
+			return
+		}
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// Optional stores the claims when the request carries a valid token and
+// lets every request through; an invalid, expired or revoked token is
+// treated like no token, so public routes keep working for the client
+func (v *Verifier) Optional() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, ok := bearer(c); ok {
+			claims, err := v.Verify(c.Request.Context(), token)
+			switch {
+			case err == nil:
+				c.Set(claimsKey, claims)
+			case !errors.Is(err, ErrInvalidToken) && !errors.Is(err, ErrRevokedToken):
+				log.Printf("auth: checking token failed: %v", err)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission lets only callers whose token grants perm through. It
+// goes after Required.
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsOf(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrMissingToken.Error()})
+			return
+		}
+		if !claims.Can(perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing permission " + perm})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ClaimsOf returns the claims stored by Required or Optional
+func ClaimsOf(c *gin.Context) (*Claims, bool) {
+	v, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(*Claims)
+	return claims, ok
+}
+
+// UserID is the caller's user id, 0 for anonymous requests
+func UserID(c *gin.Context) uint {
+	if claims, ok := ClaimsOf(c); ok {
+		return claims.UserID
+	}
+	return 0
+}
+
+// SessionID is the login session of the caller's token, 0 for anonymous requests
+func SessionID(c *gin.Context) uint {
+	if claims, ok := ClaimsOf(c); ok {
+		return claims.SessionID
+	}
+	return 0
+}
+
+// TokenID is the jti of the caller's token, "" for anonymous requests
+func TokenID(c *gin.Context) string {
+	if claims, ok := ClaimsOf(c); ok {
+		return claims.ID
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serve runs one request through the handlers; the last one answers 200
+// with the caller's user id
+func serve(header string, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	r := gin.New()
+	handlers = append(handlers, func(c *gin.Context) {
+		c.String(http.StatusOK, strconv.FormatUint(uint64(UserID(c)), 10))
+	})
+	r.GET("/", handlers...)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	keys := newTestKeys(t)
+	v := NewVerifier(keys.keyfunc, denylist("revoked-jti"))
+
+	valid := "Bearer " + keys.sign(t, validClaims())
+	revokedClaims := validClaims()
+	revokedClaims.ID = "revoked-jti"
+	revoked := "Bearer " + keys.sign(t, revokedClaims)
+	expiredClaims := validClaims()
+	expiredClaims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	expired := "Bearer " + keys.sign(t, expiredClaims)
+	viewerClaims := validClaims()
+	viewerClaims.Permissions = nil
+	viewer := "Bearer " + keys.sign(t, viewerClaims)
+
+	tests := []struct {
+		name     string
+		header   string
+		handlers []gin.HandlerFunc
+		wantCode int
+		wantBody string // checked on 200 only
+	}{
+		{"required, valid", valid, []gin.HandlerFunc{v.Required()}, http.StatusOK, "7"},
+		{"required, no header", "", []gin.HandlerFunc{v.Required()}, http.StatusUnauthorized, ""},
+		{"required, not bearer", "Basic abc", []gin.HandlerFunc{v.Required()}, http.StatusUnauthorized, ""},
+		{"required, garbage", "Bearer abc", []gin.HandlerFunc{v.Required()}, http.StatusUnauthorized, ""},
+		{"required, expired", expired, []gin.HandlerFunc{v.Required()}, http.StatusUnauthorized, ""},
+		{"required, revoked", revoked, []gin.HandlerFunc{v.Required()}, http.StatusUnauthorized, ""},
+
+		{"optional, anonymous", "", []gin.HandlerFunc{v.Optional()}, http.StatusOK, "0"},
+		{"optional, valid", valid, []gin.HandlerFunc{v.Optional()}, http.StatusOK, "7"},
+		{"optional, expired is anonymous", expired, []gin.HandlerFunc{v.Optional()}, http.StatusOK, "0"},
+		{"optional, revoked is anonymous", revoked, []gin.HandlerFunc{v.Optional()}, http.StatusOK, "0"},
+
+		{"permission, granted", valid, []gin.HandlerFunc{v.Required(), RequirePermission(PermCatalogWrite)}, http.StatusOK, "7"},
+		{"permission, missing", viewer, []gin.HandlerFunc{v.Required(), RequirePermission(PermCatalogWrite)}, http.StatusForbidden, ""},
+		{"permission, other", valid, []gin.HandlerFunc{v.Required(), RequirePermission(PermRolesManage)}, http.StatusForbidden, ""},
+		{"permission, anonymous", "", []gin.HandlerFunc{v.Optional(), RequirePermission(PermCatalogWrite)}, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.header, tt.handlers...)
+			if w.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d (%s)", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantCode == http.StatusOK && w.Body.String() != tt.wantBody {
+				t.Fatalf("user = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
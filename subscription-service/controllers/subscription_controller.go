@@ -3,6 +3,8 @@ package controllers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"os"
 	"time"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"movie-rest/pkg/auth"
 )
 
 type SubscriptionController struct {
@@ -31,8 +34,7 @@ var priceMap = map[string]int64{
 // POST /subscribe (auth required — user JWT forwarded from client)
 func (sc *SubscriptionController) CreateSubscription(c *gin.Context) {
     // get user id from token via middleware
-    uidv, _ := c.Get("user_id")
-    userID := uidv.(uint)
+    userID := auth.UserID(c)
 
     var req createSubReq
     if err := c.ShouldBindJSON(&req); err != nil {
@@ -45,6 +47,22 @@ func (sc *SubscriptionController) CreateSubscription(c *gin.Context) {
         return
     }
 
+    // users must verify their email first; checked here too, so no
+    // subscription row is stored for a purchase user-service will refuse
+    var user models.User
+    if err := sc.DB.Select("id", "email_verified_at").First(&user, userID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            c.JSON(http.StatusUnauthorized, gin.H{"error":"user not found"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error":"failed to fetch user"})
+        return
+    }
+    if user.EmailVerifiedAt == nil {
+        c.JSON(http.StatusForbidden, gin.H{"error":"verify your email before subscribing"})
+        return
+    }
+
     // compute start and end
     start := time.Now()
     var end time.Time
@@ -74,7 +92,7 @@ func (sc *SubscriptionController) CreateSubscription(c *gin.Context) {
     userSvc := os.Getenv("USER_SERVICE_URL") // e.g., http://user-service:8001
     upd := map[string]string{
         "subscription_type": req.Plan,
-        "subscription_expired_at": end.Format(time.RFC3339),
+        "expires_at": end.Format(time.RFC3339), // the field user-service binds
     }
     b, _ := json.Marshal(upd)
     client := &http.Client{ Timeout: 5 * time.Second }
@@ -88,12 +106,25 @@ func (sc *SubscriptionController) CreateSubscription(c *gin.Context) {
     }
 
     resp, err := client.Do(req2)
-    if err != nil || resp.StatusCode >= 400 {
-        // warn but keep subscription record (depending on policy you might rollback)
-        c.JSON(http.StatusOK, gin.H{
-            "message":"subscription created but failed to update user-service",
-            "subscription": sub,
-        })
+    if err != nil {
+        sc.rollback(sub)
+        c.JSON(http.StatusBadGateway, gin.H{"error":"failed to update user-service"})
+        return
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= 400 {
+        // user-service refused (e.g. 403 unverified email): the purchase did
+        // not happen, so its record goes too
+        sc.rollback(sub)
+        var body struct {
+            Error string `json:"error"`
+        }
+        json.NewDecoder(resp.Body).Decode(&body)
+        if resp.StatusCode >= 500 || body.Error == "" {
+            c.JSON(http.StatusBadGateway, gin.H{"error":"failed to update user-service"})
+            return
+        }
+        c.JSON(resp.StatusCode, gin.H{"error": body.Error})
         return
     }
 
@@ -104,9 +135,15 @@ func (sc *SubscriptionController) CreateSubscription(c *gin.Context) {
     })
 }
 
+// rollback removes the record of a subscription user-service did not apply
+func (sc *SubscriptionController) rollback(sub models.Subscription) {
+    if err := sc.DB.Delete(&sub).Error; err != nil {
+        log.Printf("subscription %d: rollback failed: %v", sub.ID, err)
+    }
+}
+
 func (sc *SubscriptionController) GetMySubscriptions(c *gin.Context) {
-    uidv, _ := c.Get("user_id")
-    userID := uidv.(uint)
+    userID := auth.UserID(c)
 
     var subs []models.Subscription
     if err := sc.DB.Where("user_id = ?", userID).Order("start_at DESC").Find(&subs).Error; err != nil {
@@ -9,6 +9,7 @@ require (
 	github.com/joho/godotenv v1.5.1
 	gorm.io/driver/postgres v1.6.0
 	gorm.io/gorm v1.31.0
+	movie-rest/pkg/auth v0.0.0
 )
 
 require (
@@ -50,3 +51,5 @@ require (
 	golang.org/x/tools v0.37.0 // indirect
 	google.golang.org/protobuf v1.36.9 // indirect
 )
+
+replace movie-rest/pkg/auth => ../pkg/auth
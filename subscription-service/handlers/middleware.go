@@ -1,48 +0,0 @@
-package handlers
-
-import (
-	"net/http"
-	"os"
-	"strings"
-
-	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-)
-
-// AuthMiddleware validates JWT and stores user_id in context
-func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		header := c.GetHeader("Authorization")
-		if header == "" || !strings.HasPrefix(header, "Bearer ") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
-			return
-		}
-
-		tokenStr := strings.TrimPrefix(header, "Bearer ")
-		secret := os.Getenv("JWT_SECRET")
-		if secret == "" {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "jwt secret not configured"})
-			return
-		}
-
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
-		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			return
-		}
-
-		claims := token.Claims.(jwt.MapClaims)
-		if uidRaw, ok := claims["user_id"]; ok {
-			if f, ok2 := uidRaw.(float64); ok2 {
-				c.Set("user_id", uint(f))
-			} else {
-				c.Set("user_id", uint(0))
-			}
-		} else {
-			c.Set("user_id", uint(0))
-		}
-		c.Next()
-	}
-}
@@ -5,11 +5,11 @@ import (
 	"os"
 	"subscription-service/connection"
 	"subscription-service/controllers"
-	"subscription-service/handlers"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"movie-rest/pkg/auth"
 )
 
 func main() {
@@ -17,6 +17,7 @@ func main() {
 
 	db := connection.Connect()
 	sc := controllers.SubscriptionController{DB: db}
+	verifier := auth.NewVerifier(auth.NewJWKSFromEnv().Keyfunc, auth.SQLDenylist(db))
 
 	r := gin.Default()
 
@@ -30,7 +31,7 @@ func main() {
 
 	// Protected routes
 	protected := r.Group("/")
-	protected.Use(handlers.AuthMiddleware())
+	protected.Use(verifier.Required())
 	{
 		protected.POST("/subscribe", sc.CreateSubscription)       // buy subscription
 		protected.GET("/subscriptions/me", sc.GetMySubscriptions) // list subscriptions for current user (optional, depends on your controller)
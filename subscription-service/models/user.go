@@ -9,5 +9,6 @@ type User struct {
 	Email                 string     `gorm:"column:email" json:"email"`
 	SubscriptionType      string     `gorm:"column:subscription_type" json:"subscription_type"`
 	SubscriptionExpiredAt *time.Time `gorm:"column:subscription_expired_at" json:"subscription_expired_at"`
+	EmailVerifiedAt       *time.Time `gorm:"column:email_verified_at" json:"email_verified_at"`
 	// Note: other columns exist in users table, but we map only the fields we need
 }
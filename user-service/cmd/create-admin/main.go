@@ -0,0 +1,80 @@
+// Command create-admin grants the admin role, creating the user first when
+// the email is not registered yet. It bootstraps the first admin, who can
+// then grant roles through /admin/users/:id/roles.
+//
+//	go run ./cmd/create-admin -email admin@example.com [-name Admin -password secret]
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	"user-service/connection"
+	"user-service/models"
+	"user-service/passwords"
+	"user-service/sessions"
+	"user-service/utils"
+
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the admin (required)")
+	name := flag.String("name", "", "name, when the user has to be created")
+	password := flag.String("password", "", "password, when the user has to be created")
+	flag.Parse()
+	// stored the way /register stores it, or the admin could not sign in
+	addr := strings.ToLower(strings.TrimSpace(*email))
+	if addr == "" {
+		log.Fatal("-email is required")
+	}
+
+	_ = godotenv.Load(".env")
+
+	db := connection.Connect()
+
+	var user models.User
+	err := db.Where("email = ?", addr).First(&user).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if *name == "" || *password == "" {
+			log.Fatal("user not found; -name and -password are required to create it")
+		}
+		if err := passwords.NewPolicyFromEnv().Check("password", *password, addr, *name); err != nil {
+			var perr *passwords.PolicyError
+			if errors.As(err, &perr) {
+				for _, v := range perr.Violations {
+					log.Printf("-password %s", v.Message)
+				}
+			}
+			log.Fatal("Password rejected:", err)
+		}
+		hash, err := utils.HashPassword(*password)
+		if err != nil {
+			log.Fatal("Failed to hash password:", err)
+		}
+		// the operator vouches for the address
+		now := time.Now()
+		user = models.User{Name: *name, Email: addr, PasswordHash: hash, EmailVerifiedAt: &now}
+		if err := db.Create(&user).Error; err != nil {
+			log.Fatal("Failed to create user:", err)
+		}
+		log.Printf("created user %d", user.ID)
+	case err != nil:
+		log.Fatal("Failed to fetch user:", err)
+	}
+
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.UserRole{UserID: user.ID, Role: models.RoleAdmin}).Error; err != nil {
+		log.Fatal("Failed to grant admin:", err)
+	}
+	log.Printf("user %d (%s) is an admin", user.ID, user.Email)
+	if sessions.NewStore(db, nil).RoleRequiresMFA(models.RoleAdmin) {
+		log.Printf("admin permissions need two-factor authentication: sign in and enable it at /me/mfa")
+	}
+}
@@ -0,0 +1,120 @@
+// Command import-breached adds passwords to the local breached list read by
+// the password policy. The input has one entry per line: a plain password,
+// or with -hashed a Pwned Passwords "SHA1:COUNT" line. Existing range files
+// are merged, so imports can be repeated.
+//
+//	go run ./cmd/import-breached -in common-passwords.txt [-hashed] [-dir ./breached-passwords]
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"user-service/passwords"
+)
+
+func main() {
+	in := flag.String("in", "", "file to import (required)")
+	hashed := flag.Bool("hashed", false, "lines are SHA1:COUNT instead of plain passwords")
+	dir := flag.String("dir", "", "list directory (default PASSWORD_BREACHED_DIR or ./breached-passwords)")
+	flag.Parse()
+	if *in == "" {
+		log.Fatal("-in is required")
+	}
+	if *dir == "" {
+		*dir = passwords.BreachedDirFromEnv()
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatal("Failed to open input:", err)
+	}
+	defer f.Close()
+
+	// prefix -> suffix -> count
+	ranges := map[string]map[string]int{}
+	add := func(hash string, count int) {
+		prefix, suffix := hash[:passwords.PrefixLen], hash[passwords.PrefixLen:]
+		if ranges[prefix] == nil {
+			ranges[prefix] = map[string]int{}
+		}
+		ranges[prefix][suffix] += count
+	}
+
+	sc := bufio.NewScanner(f)
+	lines := 0
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if !*hashed {
+			add(passwords.Hash(line), 1)
+			lines++
+			continue
+		}
+		hash, count := line, 1
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			hash = line[:i]
+			if n, err := strconv.Atoi(line[i+1:]); err == nil {
+				count = n
+			}
+		}
+		hash = strings.ToUpper(strings.TrimSpace(hash))
+		if len(hash) != 40 {
+			log.Printf("skipping malformed line %q", line)
+			continue
+		}
+		add(hash, count)
+		lines++
+	}
+	if err := sc.Err(); err != nil {
+		log.Fatal("Failed to read input:", err)
+	}
+
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	for prefix, suffixes := range ranges {
+		if err := merge(filepath.Join(*dir, prefix+".txt"), suffixes); err != nil {
+			log.Fatal("Failed to write range:", err)
+		}
+	}
+	log.Printf("imported %d entries into %d ranges in %s", lines, len(ranges), *dir)
+}
+
+// merge adds suffixes to a range file, keeping the higher of two counts
+func merge(path string, suffixes map[string]int) error {
+	if data, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			suffix, count, ok := strings.Cut(strings.TrimSpace(line), ":")
+			if !ok {
+				continue
+			}
+			n, _ := strconv.Atoi(count)
+			if n > suffixes[suffix] {
+				suffixes[suffix] = n
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	keys := make([]string, 0, len(suffixes))
+	for s := range suffixes {
+		keys = append(keys, s)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, s := range keys {
+		fmt.Fprintf(&b, "%s:%d\n", s, suffixes[s])
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
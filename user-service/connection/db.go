@@ -27,8 +27,25 @@ func Connect() *gorm.DB {
 		log.Fatal("Failed to connect DB:", err)
 	}
 
+	// accounts created before email verification count as verified
+	grandfather := !db.Migrator().HasColumn(&models.User{}, "email_verified_at")
+
 	// Auto migrate user table
-	db.AutoMigrate(&models.User{}, &models.Movie{}, &models.Watchlist{})
+	db.AutoMigrate(&models.User{}, &models.WatchProgress{}, &models.WatchHistory{},
+		&models.Session{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.UserRole{}, &models.PasswordReset{},
+		&models.UserMFA{}, &models.RecoveryCode{}, &models.LoginAttempt{}, &models.LoginCounter{},
+		&models.LoginUnlock{})
+
+	if grandfather {
+		if err := db.Exec("UPDATE users SET email_verified_at = NOW() WHERE email_verified_at IS NULL").Error; err != nil {
+			log.Fatal("Failed to mark existing users verified:", err)
+		}
+	}
+
+	// the old watchlists table becomes every user's default list
+	if err := migrateLists(db); err != nil {
+		log.Fatal("Failed to migrate lists:", err)
+	}
 
 	DB = db
 	return db
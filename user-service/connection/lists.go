@@ -0,0 +1,45 @@
+package connection
+
+import (
+	"user-service/models"
+
+	"gorm.io/gorm"
+)
+
+// migrateLists sets up the lists tables and moves the old single watchlist
+// (the watchlists table) into each user's default list. It is a no-op once
+// watchlists is gone.
+func migrateLists(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models.List{}, &models.ListItem{}); err != nil {
+		return err
+	}
+	// at most one default list per user
+	if err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_lists_user_default
+		ON lists (user_id) WHERE is_default`).Error; err != nil {
+		return err
+	}
+	if !db.Migrator().HasTable("watchlists") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO lists (user_id, title, description, privacy, slug, is_default, created_at, updated_at)
+			SELECT w.user_id, ?, '', ?, substr(md5(random()::text || w.user_id::text), 1, 16), true, now(), now()
+			FROM (SELECT DISTINCT user_id FROM watchlists) w
+			WHERE NOT EXISTS (SELECT 1 FROM lists l WHERE l.user_id = w.user_id AND l.is_default)`,
+			models.DefaultListTitle, models.PrivacyPrivate).Error; err != nil {
+			return err
+		}
+		// oldest entry first, like the order they were added in
+		if err := tx.Exec(`INSERT INTO list_items (list_id, movie_id, position, note, created_at)
+			SELECT l.id, w.movie_id,
+				ROW_NUMBER() OVER (PARTITION BY w.user_id ORDER BY w.created_at NULLS FIRST, w.movie_id),
+				'', COALESCE(w.created_at, now())
+			FROM watchlists w
+			JOIN lists l ON l.user_id = w.user_id AND l.is_default
+			ON CONFLICT (list_id, movie_id) DO NOTHING`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`DROP TABLE watchlists`).Error
+	})
+}
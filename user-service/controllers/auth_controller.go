@@ -1,30 +1,49 @@
 package controllers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"user-service/models"
+	"user-service/sessions"
+	"user-service/verification"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"movie-rest/pkg/auth"
 )
 
-func Logout(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Logged out successfully. Please remove token on client-side.",
-	})
+// Logout - POST /logout (auth required)
+// Revokes the session of the token: its refresh token stops working and the
+// access token is denylisted in every service.
+func Logout(store *sessions.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := store.Revoke(c.Request.Context(), auth.UserID(c), auth.SessionID(c))
+		if err != nil && !errors.Is(err, sessions.ErrSessionNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Logged out successfully",
+		})
+	}
 }
 
-func UpdateProfile(db *gorm.DB) gin.HandlerFunc {
+// UpdateProfile - PATCH /profile (auth required)
+// A new email address has to be verified again; one that belongs to
+// another account is refused with 409.
+func UpdateProfile(db *gorm.DB, verify *verification.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := c.GetUint("user_id") // didapat dari middleware
+		userID := auth.UserID(c) // didapat dari middleware
 
 		var req struct {
-			Name  *string `json:"name"`
-			Email *string `json:"email"`
+			Name  *string `json:"name" binding:"omitempty,max=100"`
+			Email *string `json:"email" binding:"omitempty,email,max=100"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
@@ -38,23 +57,57 @@ func UpdateProfile(db *gorm.DB) gin.HandlerFunc {
 		// Update jika ada perubahan
 		updates := map[string]interface{}{}
 		if req.Name != nil {
-			updates["name"] = *req.Name
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+				return
+			}
+			updates["name"] = name
 		}
+		emailChanged := false
+		email := ""
 		if req.Email != nil {
-			updates["email"] = *req.Email
+			email = strings.ToLower(strings.TrimSpace(*req.Email))
+			if email != user.Email {
+				if emailTaken(db, email, user.ID) {
+					c.JSON(http.StatusConflict, gin.H{"error": "email already exists"})
+					return
+				}
+				updates["email"] = email
+				updates["email_verified_at"] = nil
+				emailChanged = true
+			}
 		}
 
 		// Jika ada field yang diupdate
 		if len(updates) > 0 {
 			if err := db.Model(&user).Updates(updates).Error; err != nil {
+				// another account may have taken the address since the check
+				if emailChanged && emailTaken(db, email, user.ID) {
+					c.JSON(http.StatusConflict, gin.H{"error": "email already exists"})
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update"})
 				return
 			}
 		}
+		if emailChanged {
+			user.EmailVerifiedAt = nil
+			if err := verify.Send(user); err != nil {
+				log.Printf("profile: verification email for user %d failed: %v", user.ID, err)
+			}
+		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Profile updated",
 			"user":    user,
 		})
 	}
-}
\ No newline at end of file
+}
+
+// emailTaken tells whether another account than userID uses email
+func emailTaken(db *gorm.DB, email string, userID uint) bool {
+	var n int64
+	db.Model(&models.User{}).Where("email = ? AND id <> ?", email, userID).Count(&n)
+	return n > 0
+}
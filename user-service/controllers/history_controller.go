@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"user-service/models"
+	"user-service/movieclient"
+	"user-service/playback"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"movie-rest/pkg/auth"
+)
+
+type HistoryController struct {
+	DB      *gorm.DB
+	Tracker *playback.Tracker
+	Movies  *movieclient.Client
+}
+
+// rows read and hydrated per round trip while exporting
+const exportBatchSize = 500
+
+type historyEntry struct {
+	models.WatchHistory
+	Title string `json:"title"`
+}
+
+// the history cursor is "<last_watched_at unix nanos>:<id>" of the last row
+func encodeHistoryCursor(h models.WatchHistory) string {
+	raw := fmt.Sprintf("%d:%d", h.LastWatchedAt.UnixNano(), h.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeHistoryCursor(s string) (time.Time, uint, error) {
+	invalid := errors.New("invalid cursor")
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	ts, id, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return time.Time{}, 0, invalid
+	}
+	nanos, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	id64, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	return time.Unix(0, nanos), uint(id64), nil
+}
+
+// withTitles adds movie titles; a movie-service outage only leaves them empty
+func (hc *HistoryController) withTitles(c *gin.Context, rows []models.WatchHistory) []historyEntry {
+	ids := make([]uint, 0, len(rows))
+	for _, h := range rows {
+		ids = append(ids, h.MovieID)
+	}
+	movies, err := hc.Movies.Movies(c.Request.Context(), ids)
+	if err != nil {
+		log.Printf("history: fetching titles failed: %v", err)
+	}
+	out := make([]historyEntry, len(rows))
+	for i, h := range rows {
+		out[i] = historyEntry{WatchHistory: h, Title: movies[h.MovieID].Title}
+	}
+	return out
+}
+
+// GetHistory - GET /me/history (auth required)
+// Viewing sessions, most recent first. limit (default 20, max 100) and cursor.
+// Heartbeats show up here after the next flush of the playback tracker.
+func (hc *HistoryController) GetHistory(c *gin.Context) {
+	userID := auth.UserID(c)
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	q := hc.DB.Where("user_id = ?", userID)
+	if raw := c.Query("cursor"); raw != "" {
+		at, id, err := decodeHistoryCursor(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		q = q.Where("(last_watched_at, id) < (?, ?)", at, id)
+	}
+	var rows []models.WatchHistory
+	if err := q.Order("last_watched_at DESC, id DESC").Limit(limit + 1).Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch history"})
+		return
+	}
+
+	var nextCursor *string
+	if len(rows) > limit {
+		rows = rows[:limit]
+		cur := encodeHistoryCursor(rows[len(rows)-1])
+		nextCursor = &cur
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":        hc.withTitles(c, rows),
+		"limit":       limit,
+		"next_cursor": nextCursor,
+	})
+}
+
+// DeleteHistoryEntry - DELETE /me/history/:id (auth required)
+func (hc *HistoryController) DeleteHistoryEntry(c *gin.Context) {
+	userID := auth.UserID(c)
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	res := hc.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.WatchHistory{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete history entry"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "history entry not found"})
+		return
+	}
+	hc.Tracker.ForgetHistory(userID, id)
+	c.JSON(http.StatusOK, gin.H{"message": "history entry deleted"})
+}
+
+// ClearHistory - DELETE /me/history (auth required)
+func (hc *HistoryController) ClearHistory(c *gin.Context) {
+	userID := auth.UserID(c)
+	hc.Tracker.ForgetHistory(userID, 0)
+
+	res := hc.DB.Where("user_id = ?", userID).Delete(&models.WatchHistory{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear history"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "history cleared", "removed": res.RowsAffected})
+}
+
+// ExportHistory - GET /me/history/export?format=csv|json (auth required)
+// The whole history as a download, oldest session first, written in batches so a long
+// history is never held in memory at once.
+func (hc *HistoryController) ExportHistory(c *gin.Context) {
+	userID := auth.UserID(c)
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid format, use csv or json"})
+		return
+	}
+
+	filename := fmt.Sprintf("watch-history-%s.%s", time.Now().UTC().Format("20060102"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	var write func(entries []historyEntry) error
+	var finish func() error
+	if format == "json" {
+		c.Header("Content-Type", "application/json")
+		enc := json.NewEncoder(c.Writer)
+		sep := "["
+		write = func(entries []historyEntry) error {
+			for _, e := range entries {
+				if _, err := io.WriteString(c.Writer, sep); err != nil {
+					return err
+				}
+				sep = ","
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		finish = func() error {
+			if sep == "[" {
+				_, err := io.WriteString(c.Writer, "[]\n")
+				return err
+			}
+			_, err := io.WriteString(c.Writer, "]\n")
+			return err
+		}
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"id", "movie_id", "title", "device", "started_at", "last_watched_at", "position_seconds", "finished"})
+		write = func(entries []historyEntry) error {
+			for _, e := range entries {
+				w.Write([]string{
+					strconv.FormatUint(uint64(e.ID), 10),
+					strconv.FormatUint(uint64(e.MovieID), 10),
+					e.Title,
+					e.Device,
+					e.StartedAt.UTC().Format(time.RFC3339),
+					e.LastWatchedAt.UTC().Format(time.RFC3339),
+					strconv.Itoa(e.PositionSeconds),
+					strconv.FormatBool(e.Finished),
+				})
+			}
+			w.Flush()
+			return w.Error()
+		}
+		finish = func() error { return nil }
+	}
+	c.Status(http.StatusOK)
+
+	var rows []models.WatchHistory
+	// FindInBatches pages by id, which is also the order sessions started in
+	err := hc.DB.Where("user_id = ?", userID).FindInBatches(&rows, exportBatchSize, func(tx *gorm.DB, batch int) error {
+		return write(hc.withTitles(c, rows))
+	}).Error
+	if err == nil {
+		err = finish()
+	}
+	if err != nil {
+		// the status line is already sent, the client sees a truncated file
+		log.Printf("history: export for user %d failed: %v", userID, err)
+	}
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"user-service/models"
+	"user-service/playback"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// InternalController serves the service-to-service routes
+type InternalController struct {
+	DB       *gorm.DB
+	Playback *playback.Tracker
+}
+
+// ForgetMovie - DELETE /internal/movies/:movieId (internal token)
+// Called by movie-service after it deletes a movie; removes it from every
+// list and drops its watch progress and history.
+func (ic *InternalController) ForgetMovie(c *gin.Context) {
+	movieID, err := strconv.ParseUint(c.Param("movieId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid movie id"})
+		return
+	}
+
+	var listIDs []uint
+	if err := ic.DB.Model(&models.ListItem{}).Where("movie_id = ?", movieID).Pluck("list_id", &listIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clean up lists"})
+		return
+	}
+	var removed int64
+	for _, listID := range listIDs {
+		n, err := removeItems(ic.DB, listID, []uint{uint(movieID)})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clean up lists"})
+			return
+		}
+		removed += n
+	}
+
+	ic.Playback.Forget(uint(movieID))
+	if err := ic.DB.Where("movie_id = ?", movieID).Delete(&models.WatchProgress{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clean up watch progress"})
+		return
+	}
+	if err := ic.DB.Where("movie_id = ?", movieID).Delete(&models.WatchHistory{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clean up watch history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"removed": removed})
+}
@@ -0,0 +1,395 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"user-service/models"
+	"user-service/movieclient"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"movie-rest/pkg/auth"
+)
+
+type ListController struct {
+	DB     *gorm.DB
+	Movies *movieclient.Client
+}
+
+type createListRequest struct {
+	Title       string `json:"title" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=2000"`
+	Privacy     string `json:"privacy" binding:"omitempty,oneof=private unlisted public"`
+}
+
+type updateListRequest struct {
+	Title       *string `json:"title" binding:"omitempty,min=1,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=2000"`
+	Privacy     *string `json:"privacy" binding:"omitempty,oneof=private unlisted public"`
+}
+
+type addItemRequest struct {
+	MovieID  uint   `json:"movie_id" binding:"required"`
+	Note     string `json:"note" binding:"max=500"`
+	Position *int   `json:"position" binding:"omitempty,min=1"`
+}
+
+type updateItemRequest struct {
+	Note     *string `json:"note" binding:"omitempty,max=500"`
+	Position *int    `json:"position" binding:"omitempty,min=1"`
+}
+
+type listSummary struct {
+	models.List
+	ItemCount int64 `json:"item_count"`
+}
+
+func parseID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// summaries adds the item count to every list
+func (lc *ListController) summaries(lists []models.List) ([]listSummary, error) {
+	ids := make([]uint, len(lists))
+	for i, l := range lists {
+		ids[i] = l.ID
+	}
+	var counts []struct {
+		ListID uint
+		N      int64
+	}
+	if len(ids) > 0 {
+		if err := lc.DB.Model(&models.ListItem{}).Select("list_id, COUNT(*) AS n").
+			Where("list_id IN ?", ids).Group("list_id").Scan(&counts).Error; err != nil {
+			return nil, err
+		}
+	}
+	byList := make(map[uint]int64, len(counts))
+	for _, n := range counts {
+		byList[n.ListID] = n.N
+	}
+	out := make([]listSummary, len(lists))
+	for i, l := range lists {
+		out[i] = listSummary{List: l, ItemCount: byList[l.ID]}
+	}
+	return out, nil
+}
+
+// writeList responds with the list and its hydrated items. Items whose
+// movie is gone from movie-service are left out; only the owner's reads
+// (prune) delete them, a shared read never changes the list.
+func (lc *ListController) writeList(c *gin.Context, list *models.List, prune bool) {
+	var items []models.ListItem
+	if err := lc.DB.Where("list_id = ?", list.ID).Order("position, id").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch list items"})
+		return
+	}
+	views, missing, err := hydrateItems(c.Request.Context(), lc.Movies, items)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch movie details"})
+		return
+	}
+	if !prune {
+		c.JSON(http.StatusOK, gin.H{
+			"list":  list,
+			"items": views,
+		})
+		return
+	}
+	if len(missing) > 0 {
+		if _, err := removeItems(lc.DB, list.ID, missing); err != nil {
+			log.Printf("lists: pruning missing movies of list %d failed: %v", list.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"list":   list,
+		"items":  views,
+		"pruned": missing,
+	})
+}
+
+// GetMyLists - GET /lists (auth required)
+func (lc *ListController) GetMyLists(c *gin.Context) {
+	userID := auth.UserID(c)
+	// make sure the watchlist shows up even before anything was added to it
+	if _, err := defaultList(lc.DB, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lists"})
+		return
+	}
+
+	var lists []models.List
+	if err := lc.DB.Where("user_id = ?", userID).Order("is_default DESC, created_at, id").Find(&lists).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lists"})
+		return
+	}
+	out, err := lc.summaries(lists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lists"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// CreateList - POST /lists (auth required)
+func (lc *ListController) CreateList(c *gin.Context) {
+	var req createListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Privacy == "" {
+		req.Privacy = models.PrivacyPrivate
+	}
+	slug, err := newSlug()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create list"})
+		return
+	}
+
+	list := models.List{
+		UserID:      auth.UserID(c),
+		Title:       req.Title,
+		Description: req.Description,
+		Privacy:     req.Privacy,
+		Slug:        slug,
+	}
+	if err := lc.DB.Create(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create list"})
+		return
+	}
+	c.JSON(http.StatusCreated, list)
+}
+
+// GetList - GET /lists/:id (auth required)
+func (lc *ListController) GetList(c *gin.Context) {
+	listID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	list, err := ownList(lc.DB, listID, auth.UserID(c))
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	lc.writeList(c, list, true)
+}
+
+// UpdateList - PATCH /lists/:id (auth required)
+func (lc *ListController) UpdateList(c *gin.Context) {
+	listID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	var req updateListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	list, err := ownList(lc.DB, listID, auth.UserID(c))
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Title != nil {
+		list.Title = *req.Title
+	}
+	if req.Description != nil {
+		list.Description = *req.Description
+	}
+	if req.Privacy != nil {
+		list.Privacy = *req.Privacy
+	}
+	if err := lc.DB.Save(list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update list"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// DeleteList - DELETE /lists/:id (auth required)
+func (lc *ListController) DeleteList(c *gin.Context) {
+	listID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	list, err := ownList(lc.DB, listID, auth.UserID(c))
+	if err == nil && list.IsDefault {
+		err = errDefaultList
+	}
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	err = lc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("list_id = ?", list.ID).Delete(&models.ListItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(list).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete list"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "list deleted"})
+}
+
+// AddItem - POST /lists/:id/items (auth required)
+// Appends the movie, or inserts it at "position" when given.
+func (lc *ListController) AddItem(c *gin.Context) {
+	listID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	var req addItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	list, err := ownList(lc.DB, listID, auth.UserID(c))
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	movies, err := lc.Movies.Movies(c.Request.Context(), []uint{req.MovieID})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to verify movie"})
+		return
+	}
+	if _, ok := movies[req.MovieID]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "movie not found"})
+		return
+	}
+
+	item, err := addItem(lc.DB, list.ID, req.MovieID, req.Note, req.Position)
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, item)
+}
+
+// UpdateItem - PATCH /lists/:id/items/:movieId (auth required)
+// Changes the note and/or moves the item to "position" (1 = first).
+func (lc *ListController) UpdateItem(c *gin.Context) {
+	listID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	movieID, ok := parseID(c, "movieId")
+	if !ok {
+		return
+	}
+	var req updateItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	list, err := ownList(lc.DB, listID, auth.UserID(c))
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var item models.ListItem
+	err = lc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockList(tx, list.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("list_id = ? AND movie_id = ?", list.ID, movieID).First(&item).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errItemNotFound
+			}
+			return err
+		}
+		if req.Note != nil {
+			if err := tx.Model(&item).Update("note", *req.Note).Error; err != nil {
+				return err
+			}
+		}
+		if req.Position != nil {
+			if err := moveItem(tx, list.ID, movieID, *req.Position); err != nil {
+				return err
+			}
+		}
+		return tx.First(&item, item.ID).Error
+	})
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// RemoveItem - DELETE /lists/:id/items/:movieId (auth required)
+func (lc *ListController) RemoveItem(c *gin.Context) {
+	listID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	movieID, ok := parseID(c, "movieId")
+	if !ok {
+		return
+	}
+	list, err := ownList(lc.DB, listID, auth.UserID(c))
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	removed, err := removeItems(lc.DB, list.ID, []uint{movieID})
+	if err == nil && removed == 0 {
+		err = errItemNotFound
+	}
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "movie removed from list"})
+}
+
+// GetSharedList - GET /shared/lists/:slug (public)
+// Unlisted and public lists are readable by anyone who has the slug.
+func (lc *ListController) GetSharedList(c *gin.Context) {
+	var list models.List
+	if err := lc.DB.Where("slug = ? AND privacy <> ?", c.Param("slug"), models.PrivacyPrivate).
+		First(&list).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": errListNotFound.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch list"})
+		return
+	}
+	lc.writeList(c, &list, false)
+}
+
+// GetUserLists - GET /users/:id/lists (public)
+// Only public lists are shown on a profile.
+func (lc *ListController) GetUserLists(c *gin.Context) {
+	userID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	var lists []models.List
+	if err := lc.DB.Where("user_id = ? AND privacy = ?", userID, models.PrivacyPublic).
+		Order("created_at, id").Find(&lists).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lists"})
+		return
+	}
+	out, err := lc.summaries(lists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lists"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
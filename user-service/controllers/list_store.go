@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+	"user-service/models"
+	"user-service/movieclient"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errListNotFound = errors.New("list not found")
+	errItemNotFound = errors.New("movie is not in this list")
+	errItemExists   = errors.New("movie is already in this list")
+	errDefaultList  = errors.New("the default list cannot be deleted")
+)
+
+func newSlug() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// defaultList returns the user's default list, creating it on first use
+func defaultList(db *gorm.DB, userID uint) (*models.List, error) {
+	var list models.List
+	err := db.Where("user_id = ? AND is_default", userID).First(&list).Error
+	if err == nil {
+		return &list, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	slug, err := newSlug()
+	if err != nil {
+		return nil, err
+	}
+	list = models.List{
+		UserID:    userID,
+		Title:     models.DefaultListTitle,
+		Privacy:   models.PrivacyPrivate,
+		Slug:      slug,
+		IsDefault: true,
+	}
+	// a concurrent request may have created it first
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&list).Error; err != nil {
+		return nil, err
+	}
+	if list.ID != 0 {
+		return &list, nil
+	}
+	if err := db.Where("user_id = ? AND is_default", userID).First(&list).Error; err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// ownList loads a list of the user; other users' lists are reported as not found
+func ownList(db *gorm.DB, listID, userID uint) (*models.List, error) {
+	var list models.List
+	if err := db.Where("id = ? AND user_id = ?", listID, userID).First(&list).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errListNotFound
+		}
+		return nil, err
+	}
+	return &list, nil
+}
+
+// lockList serializes item changes of one list
+func lockList(tx *gorm.DB, listID uint) error {
+	var list models.List
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&list, listID).Error
+}
+
+// addItem appends movieID to the list, then moves it to position if given
+func addItem(db *gorm.DB, listID, movieID uint, note string, position *int) (*models.ListItem, error) {
+	var item models.ListItem
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockList(tx, listID); err != nil {
+			return err
+		}
+		var exists int64
+		if err := tx.Model(&models.ListItem{}).Where("list_id = ? AND movie_id = ?", listID, movieID).
+			Count(&exists).Error; err != nil {
+			return err
+		}
+		if exists > 0 {
+			return errItemExists
+		}
+
+		var last int
+		if err := tx.Model(&models.ListItem{}).Where("list_id = ?", listID).
+			Select("COALESCE(MAX(position), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+		item = models.ListItem{ListID: listID, MovieID: movieID, Position: last + 1, Note: note}
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		if position != nil {
+			if err := moveItem(tx, listID, movieID, *position); err != nil {
+				return err
+			}
+			return tx.First(&item, item.ID).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// moveItem puts movieID at position (1-based, clamped to the list) and
+// renumbers the items between its old and new place. The caller holds the
+// list lock.
+func moveItem(tx *gorm.DB, listID, movieID uint, position int) error {
+	var items []models.ListItem
+	if err := tx.Select("id", "movie_id", "position").Where("list_id = ?", listID).
+		Order("position, id").Find(&items).Error; err != nil {
+		return err
+	}
+	from := -1
+	for i, it := range items {
+		if it.MovieID == movieID {
+			from = i
+			break
+		}
+	}
+	if from < 0 {
+		return errItemNotFound
+	}
+
+	to := position - 1
+	if to < 0 {
+		to = 0
+	}
+	if to > len(items)-1 {
+		to = len(items) - 1
+	}
+	moved := items[from]
+	items = append(items[:from], items[from+1:]...)
+	items = append(items[:to], append([]models.ListItem{moved}, items[to:]...)...)
+
+	for i, it := range items {
+		if it.Position == i+1 {
+			continue
+		}
+		if err := tx.Model(&models.ListItem{}).Where("id = ?", it.ID).Update("position", i+1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renumber closes the gaps left by removed items
+func renumber(tx *gorm.DB, listID uint) error {
+	return tx.Exec(`UPDATE list_items SET position = r.rn
+		FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY position, id) AS rn FROM list_items WHERE list_id = ?) r
+		WHERE list_items.id = r.id AND list_items.position <> r.rn`, listID).Error
+}
+
+// removeItems deletes movieIDs from the list and renumbers the rest
+func removeItems(db *gorm.DB, listID uint, movieIDs []uint) (int64, error) {
+	var removed int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockList(tx, listID); err != nil {
+			return err
+		}
+		res := tx.Where("list_id = ? AND movie_id IN ?", listID, movieIDs).Delete(&models.ListItem{})
+		if res.Error != nil {
+			return res.Error
+		}
+		removed = res.RowsAffected
+		if removed == 0 {
+			return nil
+		}
+		return renumber(tx, listID)
+	})
+	return removed, err
+}
+
+type listItemView struct {
+	MovieID         uint      `json:"movie_id"`
+	Title           string    `json:"title"`
+	PosterURL       string    `json:"poster_url"`
+	DurationMinutes int       `json:"duration_minutes"`
+	ReleaseYear     int       `json:"release_year"`
+	Rating          float64   `json:"rating"`
+	IsPremium       bool      `json:"is_premium"`
+	Position        int       `json:"position"`
+	Note            string    `json:"note"`
+	AddedAt         time.Time `json:"added_at"`
+}
+
+// hydrateItems fetches the movie details of items from movie-service.
+// Items whose movie no longer exists are returned in missing instead.
+func hydrateItems(ctx context.Context, movies *movieclient.Client, items []models.ListItem) ([]listItemView, []uint, error) {
+	ids := make([]uint, len(items))
+	for i, it := range items {
+		ids[i] = it.MovieID
+	}
+	found, err := movies.Movies(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	views := make([]listItemView, 0, len(items))
+	missing := make([]uint, 0)
+	for _, it := range items {
+		m, ok := found[it.MovieID]
+		if !ok {
+			missing = append(missing, it.MovieID)
+			continue
+		}
+		views = append(views, listItemView{
+			MovieID:         m.ID,
+			Title:           m.Title,
+			PosterURL:       m.PosterURL,
+			DurationMinutes: m.DurationMinutes,
+			ReleaseYear:     m.ReleaseYear,
+			Rating:          m.Rating,
+			IsPremium:       m.IsPremium,
+			Position:        it.Position,
+			Note:            it.Note,
+			AddedAt:         it.CreatedAt,
+		})
+	}
+	return views, missing, nil
+}
+
+func listErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errListNotFound), errors.Is(err, errItemNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errItemExists):
+		return http.StatusConflict
+	case errors.Is(err, errDefaultList):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"user-service/mfa"
+	"user-service/models"
+	"user-service/ratelimit"
+	"user-service/sessions"
+	"user-service/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"movie-rest/pkg/auth"
+)
+
+// MFAController manages the caller's two-factor authentication
+type MFAController struct {
+	DB       *gorm.DB
+	MFA      *mfa.Service
+	Sessions *sessions.Store
+	Attempts *ratelimit.Limiter // code guesses per user
+}
+
+type mfaCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type disableMFARequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP or recovery code
+}
+
+func (mc *MFAController) currentUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+	if err := mc.DB.First(&user, auth.UserID(c)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return user, false
+	}
+	return user, true
+}
+
+// allowAttempt caps code guesses, like the second login step
+func (mc *MFAController) allowAttempt(c *gin.Context) bool {
+	if ok, retry := mc.Attempts.Allow(strconv.FormatUint(uint64(auth.UserID(c)), 10)); !ok {
+		ratelimit.Reject(c, retry)
+		return false
+	}
+	return true
+}
+
+// requiredFor reports whether one of the user's roles makes 2FA mandatory
+func (mc *MFAController) requiredFor(userID uint) (bool, error) {
+	var roles []string
+	if err := mc.DB.Model(&models.UserRole{}).Where("user_id = ?", userID).Pluck("role", &roles).Error; err != nil {
+		return false, err
+	}
+	for _, r := range roles {
+		if mc.Sessions.RoleRequiresMFA(r) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetMFA - GET /me/mfa (auth required)
+func (mc *MFAController) GetMFA(c *gin.Context) {
+	userID := auth.UserID(c)
+	enabled, err := mc.MFA.Enabled(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch two-factor status"})
+		return
+	}
+	required, err := mc.requiredFor(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch two-factor status"})
+		return
+	}
+	left, err := mc.MFA.RecoveryCodesLeft(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch two-factor status"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled, "required": required, "recovery_codes_left": left})
+}
+
+// EnrollMFA - POST /me/mfa/enroll (auth required)
+// Returns a new secret and its otpauth:// URI, the QR code payload. 2FA is
+// on only after POST /me/mfa/confirm.
+func (mc *MFAController) EnrollMFA(c *gin.Context) {
+	user, ok := mc.currentUser(c)
+	if !ok {
+		return
+	}
+	secret, uri, err := mc.MFA.Enroll(c.Request.Context(), user)
+	if err != nil {
+		if errors.Is(err, mfa.ErrAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start enrollment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_uri": uri, "qr_payload": uri})
+}
+
+// ConfirmMFA - POST /me/mfa/confirm (auth required)
+// Turns 2FA on with a first code and returns the recovery codes, which are
+// never shown again.
+func (mc *MFAController) ConfirmMFA(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !mc.allowAttempt(c) {
+		return
+	}
+	userID := auth.UserID(c)
+	codes, err := mc.MFA.Confirm(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrNotEnrolled):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, mfa.ErrInvalidCode):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm enrollment"})
+		}
+		return
+	}
+	// roles withheld for lack of 2FA come with the next refresh
+	if err := mc.Sessions.ExpireAccess(c.Request.Context(), userID); err != nil {
+		log.Printf("mfa: expiring tokens of user %d failed: %v", userID, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes - POST /me/mfa/recovery-codes (auth required)
+// Needs a current code; the old recovery codes stop working.
+func (mc *MFAController) RegenerateRecoveryCodes(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !mc.allowAttempt(c) {
+		return
+	}
+	userID := auth.UserID(c)
+	if err := mc.MFA.Verify(c.Request.Context(), userID, req.Code); err != nil {
+		mfaCodeFailed(c, err)
+		return
+	}
+	codes, err := mc.MFA.RegenerateRecoveryCodes(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableMFA - DELETE /me/mfa (auth required)
+// A valid token is not enough: the password and a code are asked again.
+// Users whose role requires 2FA cannot turn it off.
+func (mc *MFAController) DisableMFA(c *gin.Context) {
+	var req disableMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := mc.currentUser(c)
+	if !ok {
+		return
+	}
+	required, err := mc.requiredFor(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch roles"})
+		return
+	}
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": "two-factor authentication is required for your role"})
+		return
+	}
+	if !mc.allowAttempt(c) {
+		return
+	}
+	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid password"})
+		return
+	}
+	if err := mc.MFA.Verify(c.Request.Context(), user.ID, req.Code); err != nil {
+		mfaCodeFailed(c, err)
+		return
+	}
+
+	if err := mc.MFA.Disable(c.Request.Context(), user.ID); err != nil && !errors.Is(err, mfa.ErrNotEnabled) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable two-factor authentication"})
+		return
+	}
+	log.Printf("mfa: user %d disabled two-factor authentication", user.ID)
+	c.JSON(http.StatusOK, gin.H{"enabled": false})
+}
+
+func mfaCodeFailed(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, mfa.ErrNotEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, mfa.ErrInvalidCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check code"})
+	}
+}
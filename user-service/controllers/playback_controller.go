@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+	"user-service/models"
+	"user-service/movieclient"
+	"user-service/playback"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"movie-rest/pkg/auth"
+)
+
+type PlaybackController struct {
+	DB      *gorm.DB
+	Tracker *playback.Tracker
+	Movies  *movieclient.Client
+}
+
+type progressRequest struct {
+	MovieID         uint   `json:"movie_id" binding:"required"`
+	PositionSeconds *int   `json:"position_seconds" binding:"required,min=0"`
+	Device          string `json:"device" binding:"max=100"` // defaults to the User-Agent
+}
+
+type continueItem struct {
+	MovieID         uint      `json:"movie_id"`
+	Title           string    `json:"title"`
+	PosterURL       string    `json:"poster_url"`
+	IsPremium       bool      `json:"is_premium"`
+	PositionSeconds int       `json:"position_seconds"`
+	DurationSeconds int       `json:"duration_seconds"`
+	Progress        float64   `json:"progress"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// RecordProgress - POST /me/progress (auth required)
+// Player heartbeat; buffered in memory and flushed in batches.
+func (pc *PlaybackController) RecordProgress(c *gin.Context) {
+	var req progressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	device := req.Device
+	if device == "" {
+		device = c.GetHeader("User-Agent")
+		if r := []rune(device); len(r) > 100 {
+			device = string(r[:100])
+		}
+	}
+
+	p, err := pc.Tracker.Heartbeat(c.Request.Context(), auth.UserID(c), req.MovieID, *req.PositionSeconds, device, time.Now())
+	if err != nil {
+		if errors.Is(err, playback.ErrMovieNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "movie not found"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch movie duration"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"movie_id":         p.MovieID,
+		"position_seconds": p.PositionSeconds,
+		"duration_seconds": p.DurationSeconds,
+		"finished":         p.Finished,
+	})
+}
+
+// ContinueWatching - GET /me/continue-watching (auth required)
+// Started but unfinished movies, most recently watched first. limit default 20, max 50.
+func (pc *PlaybackController) ContinueWatching(c *gin.Context) {
+	userID := auth.UserID(c)
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	if limit > 50 {
+		limit = 50
+	}
+
+	var rows []models.WatchProgress
+	if err := pc.DB.Where("user_id = ?", userID).Order("updated_at DESC").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch watch progress"})
+		return
+	}
+	// heartbeats not flushed yet are newer than what is stored
+	latest := make(map[uint]models.WatchProgress, len(rows))
+	for _, p := range rows {
+		latest[p.MovieID] = p
+	}
+	for _, p := range pc.Tracker.Pending(userID) {
+		if cur, ok := latest[p.MovieID]; !ok || p.UpdatedAt.After(cur.UpdatedAt) {
+			latest[p.MovieID] = p
+		}
+	}
+
+	started := make([]models.WatchProgress, 0, len(latest))
+	for _, p := range latest {
+		if !p.Finished && p.PositionSeconds > 0 {
+			started = append(started, p)
+		}
+	}
+	sort.Slice(started, func(i, j int) bool {
+		if !started[i].UpdatedAt.Equal(started[j].UpdatedAt) {
+			return started[i].UpdatedAt.After(started[j].UpdatedAt)
+		}
+		return started[i].MovieID < started[j].MovieID
+	})
+	if len(started) > limit {
+		started = started[:limit]
+	}
+
+	ids := make([]uint, len(started))
+	for i, p := range started {
+		ids[i] = p.MovieID
+	}
+	movies, err := pc.Movies.Movies(c.Request.Context(), ids)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch movie details"})
+		return
+	}
+
+	out := make([]continueItem, 0, len(started))
+	for _, p := range started {
+		m, ok := movies[p.MovieID]
+		if !ok {
+			continue
+		}
+		progress := 0.0
+		if p.DurationSeconds > 0 {
+			progress = float64(p.PositionSeconds) / float64(p.DurationSeconds)
+		}
+		out = append(out, continueItem{
+			MovieID:         m.ID,
+			Title:           m.Title,
+			PosterURL:       m.PosterURL,
+			IsPremium:       m.IsPremium,
+			PositionSeconds: p.PositionSeconds,
+			DurationSeconds: p.DurationSeconds,
+			Progress:        progress,
+			UpdatedAt:       p.UpdatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
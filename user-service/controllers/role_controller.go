@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"user-service/models"
+	"user-service/sessions"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"movie-rest/pkg/auth"
+)
+
+var errLastAdmin = errors.New("cannot revoke the last admin")
+
+// RoleController serves the admin endpoints that grant and revoke roles
+type RoleController struct {
+	DB       *gorm.DB
+	Sessions *sessions.Store
+}
+
+type grantRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=editor admin"`
+}
+
+func (rc *RoleController) grants(userID uint) (gin.H, error) {
+	var stored []string
+	if err := rc.DB.Model(&models.UserRole{}).Where("user_id = ?", userID).Pluck("role", &stored).Error; err != nil {
+		return nil, err
+	}
+	roles, perms := models.Grants(stored)
+	if perms == nil {
+		perms = []string{}
+	}
+	return gin.H{"user_id": userID, "roles": roles, "permissions": perms}, nil
+}
+
+// targetUser reads :id and checks the user exists
+func (rc *RoleController) targetUser(c *gin.Context) (uint, bool) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return 0, false
+	}
+	var user models.User
+	if err := rc.DB.Select("id").First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return 0, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+		return 0, false
+	}
+	return id, true
+}
+
+// expireAccess makes the user's clients refresh, so their tokens carry the
+// new roles right away instead of after ACCESS_TOKEN_TTL
+func (rc *RoleController) expireAccess(c *gin.Context, userID uint) {
+	if err := rc.Sessions.ExpireAccess(c.Request.Context(), userID); err != nil {
+		log.Printf("roles: expiring tokens of user %d failed: %v", userID, err)
+	}
+}
+
+// GetRoles - GET /admin/users/:id/roles (roles:manage)
+func (rc *RoleController) GetRoles(c *gin.Context) {
+	userID, ok := rc.targetUser(c)
+	if !ok {
+		return
+	}
+	out, err := rc.grants(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch roles"})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GrantRole - POST /admin/users/:id/roles (roles:manage)
+// Body {"role": "editor"|"admin"}. Granting a role twice is a no-op.
+func (rc *RoleController) GrantRole(c *gin.Context) {
+	userID, ok := rc.targetUser(c)
+	if !ok {
+		return
+	}
+	var req grantRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	grantedBy := auth.UserID(c)
+	if err := rc.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UserRole{
+		UserID:    userID,
+		Role:      req.Role,
+		GrantedBy: &grantedBy,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to grant role"})
+		return
+	}
+	rc.expireAccess(c, userID)
+
+	out, err := rc.grants(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch roles"})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// RevokeRole - DELETE /admin/users/:id/roles/:role (roles:manage)
+// The last admin cannot be revoked, so the system never locks itself out.
+func (rc *RoleController) RevokeRole(c *gin.Context) {
+	userID, ok := rc.targetUser(c)
+	if !ok {
+		return
+	}
+	role := c.Param("role")
+
+	err := rc.DB.Transaction(func(tx *gorm.DB) error {
+		if role == models.RoleAdmin {
+			// serializes concurrent admin revocations
+			if err := tx.Exec("LOCK TABLE user_roles IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+				return err
+			}
+			var admins int64
+			if err := tx.Model(&models.UserRole{}).Where("role = ? AND user_id <> ?", models.RoleAdmin, userID).
+				Count(&admins).Error; err != nil {
+				return err
+			}
+			if admins == 0 {
+				return errLastAdmin
+			}
+		}
+		res := tx.Where("user_id = ? AND role = ?", userID, role).Delete(&models.UserRole{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "role not granted"})
+		return
+	case errors.Is(err, errLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": errLastAdmin.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke role"})
+		return
+	}
+	rc.expireAccess(c, userID)
+
+	out, err := rc.grants(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch roles"})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"user-service/sessions"
+
+	"github.com/gin-gonic/gin"
+	"movie-rest/pkg/auth"
+)
+
+type SessionController struct {
+	Store *sessions.Store
+}
+
+type sessionView struct {
+	ID         uint      `json:"id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// GetSessions - GET /me/sessions (auth required)
+// Logged-in devices of the caller, most recently used first.
+func (sc *SessionController) GetSessions(c *gin.Context) {
+	list, err := sc.Store.Active(c.Request.Context(), auth.UserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+		return
+	}
+	current := auth.SessionID(c)
+	out := make([]sessionView, 0, len(list))
+	for _, s := range list {
+		out = append(out, sessionView{
+			ID:         s.ID,
+			Device:     s.Device,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			ExpiresAt:  s.ExpiresAt,
+			Current:    s.ID == current,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// DeleteSession - DELETE /me/sessions/:id (auth required)
+// Logs one device out; revoking the current session works like /logout.
+func (sc *SessionController) DeleteSession(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	if err := sc.Store.Revoke(c.Request.Context(), auth.UserID(c), id); err != nil {
+		if errors.Is(err, sessions.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+	"user-service/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"movie-rest/pkg/auth"
+)
+
+type SignalsController struct {
+	DB *gorm.DB
+}
+
+type watchlistSignal struct {
+	MovieID uint      `json:"movie_id"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type historySignal struct {
+	MovieID       uint      `json:"movie_id"`
+	Finished      bool      `json:"finished"`
+	LastWatchedAt time.Time `json:"last_watched_at"`
+}
+
+// GetSignals - GET /profile/signals (auth required)
+// Taste signals of the caller, read by movie-service for personalized
+// recommendations (it forwards the user's token).
+func (sc *SignalsController) GetSignals(c *gin.Context) {
+	userID := auth.UserID(c)
+
+	// every movie saved to any of the user's lists, first save wins
+	var watchlist []watchlistSignal
+	if err := sc.DB.Model(&models.ListItem{}).
+		Select("list_items.movie_id, MIN(list_items.created_at) AS added_at").
+		Joins("JOIN lists ON lists.id = list_items.list_id").
+		Where("lists.user_id = ?", userID).
+		Group("list_items.movie_id").Order("added_at DESC").
+		Scan(&watchlist).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch watchlist"})
+		return
+	}
+	if watchlist == nil {
+		watchlist = []watchlistSignal{}
+	}
+
+	// every watched movie, finished if any session got to the end
+	var history []historySignal
+	if err := sc.DB.Model(&models.WatchHistory{}).
+		Select("movie_id, BOOL_OR(finished) AS finished, MAX(last_watched_at) AS last_watched_at").
+		Where("user_id = ?", userID).
+		Group("movie_id").Order("last_watched_at DESC").
+		Scan(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch watch history"})
+		return
+	}
+	if history == nil {
+		history = []historySignal{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":   userID,
+		"watchlist": watchlist,
+		"history":   history,
+	})
+}
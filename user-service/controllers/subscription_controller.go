@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"movie-rest/pkg/auth"
 )
 
 // PATCH /subscribe  -> protected by JWT middleware
@@ -20,7 +21,7 @@ type SubscriptionController struct {
 }
 
 func (sc *SubscriptionController) UpdateUserSubscription(c *gin.Context) {
-    uid := c.GetUint("user_id")
+    uid := auth.UserID(c)
     if uid == 0 {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
         return
@@ -38,6 +39,12 @@ func (sc *SubscriptionController) UpdateUserSubscription(c *gin.Context) {
         return
     }
 
+    // unverified accounts may only cancel
+    if !user.Verified() && req.SubscriptionType != "none" {
+        c.JSON(http.StatusForbidden, gin.H{"error": "verify your email before subscribing"})
+        return
+    }
+
     user.SubscriptionType = req.SubscriptionType
     if req.ExpiresAt != nil {
         user.SubscriptionExpiredAt = req.ExpiresAt
@@ -2,18 +2,21 @@ package controllers
 
 import (
 	"net/http"
+	"user-service/handlers"
 	"user-service/models"
+	"user-service/passwords"
 	"user-service/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"movie-rest/pkg/auth"
 )
 
 // GetProfile - GET /profile (auth required)
 // Mengembalikan detail lengkap dari pengguna yang sedang login
 func GetProfile(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := c.GetUint("user_id")
+		userID := auth.UserID(c)
 
 		var user models.User
 		// Menggunakan First untuk mencari user berdasarkan Primary Key (ID)
@@ -33,6 +36,7 @@ func GetProfile(db *gorm.DB) gin.HandlerFunc {
 			"email":                   user.Email,
 			"subscription_type":       user.SubscriptionType,
 			"subscription_expired_at": user.SubscriptionExpiredAt,
+			"email_verified":          user.Verified(),
 		})
 	}
 }
@@ -43,9 +47,9 @@ type changePasswordRequest struct {
 }
 
 // ChangePassword - PATCH /profile/password (auth required)
-func ChangePassword(db *gorm.DB) gin.HandlerFunc {
+func ChangePassword(db *gorm.DB, policy *passwords.Policy) gin.HandlerFunc {
     return func(c *gin.Context) {
-        userID := c.GetUint("user_id")
+        userID := auth.UserID(c)
 
         // 1. Bind request body ke struct
         var req changePasswordRequest
@@ -67,14 +71,20 @@ func ChangePassword(db *gorm.DB) gin.HandlerFunc {
             return
         }
 
-        // 4. Hash password baru
+        // 4. Cek password baru terhadap policy
+        if err := policy.Check("new_password", req.NewPassword, user.Email, user.Name); err != nil {
+            handlers.PasswordRejected(c, err)
+            return
+        }
+
+        // 5. Hash password baru
         newHashedPassword, err := utils.HashPassword(req.NewPassword)
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash new password"})
             return
         }
 
-        // 5. Update password di database
+        // 6. Update password di database
         if err := db.Model(&user).Update("password_hash", newHashedPassword).Error; err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update password"})
             return
@@ -1,21 +1,29 @@
 package controllers
 
 import (
+	"cmp"
+	"errors"
+	"log"
 	"net/http"
+	"sort"
 	"strconv"
-	"user-service/models"
+	"strings"
+		"user-service/models"
+	"user-service/movieclient"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"movie-rest/pkg/auth"
 )
 
 type WatchlistController struct {
-    DB *gorm.DB
+    DB     *gorm.DB
+    Movies *movieclient.Client
 }
 
 // POST /profile/watchlist
 func (wc *WatchlistController) AddToWatchlist(c *gin.Context) {
-    userID := c.GetUint("user_id")
+    userID := auth.UserID(c)
 
     var req struct {
         MovieID uint `json:"movie_id" binding:"required"`
@@ -26,18 +34,24 @@ func (wc *WatchlistController) AddToWatchlist(c *gin.Context) {
         return
     }
 
-    // Buat entri film jika belum ada (hanya ID-nya saja)
-    movie := models.Movie{ID: req.MovieID}
-    wc.DB.FirstOrCreate(&movie, movie)
-
-    // Tambahkan asosiasi ke watchlist
-    var user models.User
-    if err := wc.DB.First(&user, userID).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+    // Pastikan filmnya ada di movie-service
+    movies, err := wc.Movies.Movies(c.Request.Context(), []uint{req.MovieID})
+    if err != nil {
+        c.JSON(http.StatusBadGateway, gin.H{"error": "failed to verify movie"})
+        return
+    }
+    if _, ok := movies[req.MovieID]; !ok {
+        c.JSON(http.StatusNotFound, gin.H{"error": "movie not found"})
         return
     }
 
-    if err := wc.DB.Model(&user).Association("Watchlist").Append(&movie); err != nil {
+    // the watchlist is the user's default list; adding a movie twice is a no-op
+    list, err := defaultList(wc.DB, userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add movie to watchlist"})
+        return
+    }
+    if _, err := addItem(wc.DB, list.ID, req.MovieID, "", nil); err != nil && !errors.Is(err, errItemExists) {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add movie to watchlist"})
         return
     }
@@ -46,33 +60,154 @@ func (wc *WatchlistController) AddToWatchlist(c *gin.Context) {
 }
 
 // GET /profile/watchlist
+// Items of the default list, hydrated through movie-service. Query: sort
+// (added_at|title|rating|position, default added_at), order (asc|desc, default desc), limit (default 20, max 100), offset.
+// Entries whose movie no longer exists are pruned and reported in "pruned".
 func (wc *WatchlistController) GetWatchlist(c *gin.Context) {
-    userID := c.GetUint("user_id")
+    userID := auth.UserID(c)
 
-    var user models.User
-    // Menggunakan Preload untuk mengambil data film (hanya ID) dari tabel asosiasi
-    if err := wc.DB.Preload("Watchlist").First(&user, userID).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+    sortBy := c.DefaultQuery("sort", "added_at")
+    if sortBy != "added_at" && sortBy != "title" && sortBy != "rating" && sortBy != "position" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort, use added_at, title, rating or position"})
+        return
+    }
+    order := strings.ToLower(c.DefaultQuery("order", "desc"))
+    if order != "asc" && order != "desc" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order, use asc or desc"})
+        return
+    }
+    limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+    if err != nil || limit <= 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+        return
+    }
+    if limit > 100 {
+        limit = 100
+    }
+    offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+    if err != nil || offset < 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
         return
     }
 
-    // Mengekstrak hanya ID film dari hasil preload
-    movieIDs := make([]uint, len(user.Watchlist))
-    for i, movie := range user.Watchlist {
-        movieIDs[i] = movie.ID
+    list, err := defaultList(wc.DB, userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch watchlist"})
+        return
     }
-    
-    // Di aplikasi nyata, Anda akan memanggil movie-service di sini untuk mendapatkan detail film
-    // Untuk saat ini, kita hanya kembalikan daftar ID-nya.
+    var rows []models.ListItem
+    if err := wc.DB.Where("list_id = ?", list.ID).Find(&rows).Error; err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch watchlist"})
+        return
+    }
+
+    items, pruned, err := hydrateItems(c.Request.Context(), wc.Movies, rows)
+    if err != nil {
+        c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch movie details"})
+        return
+    }
+
+    // film yang sudah dihapus di movie-service dibuang dari watchlist
+    if len(pruned) > 0 {
+        if _, err := removeItems(wc.DB, list.ID, pruned); err != nil {
+            log.Printf("watchlist: pruning missing movies for user %d failed: %v", userID, err)
+        }
+    }
+
+    sortWatchlist(items, sortBy, order == "desc")
+
+    movieIDs := make([]uint, len(items))
+    for i, it := range items {
+        movieIDs[i] = it.MovieID
+    }
+    total := len(items)
+    if offset > total {
+        offset = total
+    }
+    end := offset + limit
+    if end > total {
+        end = total
+    }
+
     c.JSON(http.StatusOK, gin.H{
-        "user_id": userID,
+        "user_id":             userID,
+        "list_id":             list.ID,
         "watchlist_movie_ids": movieIDs,
+        "data":                items[offset:end],
+        "total":               total,
+        "limit":               limit,
+        "offset":              offset,
+        "pruned":              pruned,
+    })
+}
+
+// sortWatchlist orders items by key; movie_id breaks ties so pages are stable
+func sortWatchlist(items []listItemView, key string, desc bool) {
+    compare := func(a, b listItemView) int {
+        switch key {
+        case "title":
+            return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+        case "rating":
+            return cmp.Compare(a.Rating, b.Rating)
+        case "position":
+            return cmp.Compare(a.Position, b.Position)
+        default:
+            return a.AddedAt.Compare(b.AddedAt)
+        }
+    }
+    sort.SliceStable(items, func(i, j int) bool {
+        c := compare(items[i], items[j])
+        if c == 0 {
+            return items[i].MovieID < items[j].MovieID
+        }
+        if desc {
+            return c > 0
+        }
+        return c < 0
     })
 }
 
+// GET /profile/watchlist/ids?movie_ids=1,2,3
+// Which of the given movies are on the default list, for movie-service's
+// in_watchlist flags (it forwards the user's token). At most 100 ids.
+func (wc *WatchlistController) GetWatchlistIDs(c *gin.Context) {
+    userID := auth.UserID(c)
+
+    var ids []uint
+    for _, part := range strings.Split(c.Query("movie_ids"), ",") {
+        if part = strings.TrimSpace(part); part == "" {
+            continue
+        }
+        id, err := strconv.ParseUint(part, 10, 32)
+        if err != nil || id == 0 {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid movie_ids"})
+            return
+        }
+        ids = append(ids, uint(id))
+    }
+    if len(ids) > 100 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "at most 100 movie_ids"})
+        return
+    }
+
+    saved := []uint{}
+    if len(ids) > 0 {
+        // read only; the default list is not created here
+        if err := wc.DB.Model(&models.ListItem{}).
+            Joins("JOIN lists ON lists.id = list_items.list_id").
+            Where("lists.user_id = ? AND lists.is_default AND list_items.movie_id IN ?", userID, ids).
+            Pluck("list_items.movie_id", &saved).Error; err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch watchlist"})
+            return
+        }
+    }
+
+    c.JSON(http.StatusOK, gin.H{"movie_ids": saved})
+}
+
 // DELETE /profile/watchlist/:movieId
 func (wc *WatchlistController) RemoveFromWatchlist(c *gin.Context) {
-    userID := c.GetUint("user_id")
+    userID := auth.UserID(c)
     movieIDStr := c.Param("movieId")
     
     movieID, err := strconv.ParseUint(movieIDStr, 10, 32)
@@ -81,19 +216,16 @@ func (wc *WatchlistController) RemoveFromWatchlist(c *gin.Context) {
         return
     }
 
-    var user models.User
-    if err := wc.DB.First(&user, userID).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+    // Menghapus film dari watchlist (default list)
+    list, err := defaultList(wc.DB, userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove movie from watchlist"})
         return
     }
-
-    movie := models.Movie{ID: uint(movieID)}
-
-    // Menghapus asosiasi dari watchlist
-    if err := wc.DB.Model(&user).Association("Watchlist").Delete(&movie); err != nil {
+    if _, err := removeItems(wc.DB, list.ID, []uint{uint(movieID)}); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove movie from watchlist"})
         return
     }
 
     c.JSON(http.StatusOK, gin.H{"message": "movie removed from watchlist"})
-}
\ No newline at end of file
+}
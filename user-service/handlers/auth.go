@@ -1,52 +1,122 @@
 package handlers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"user-service/loginguard"
+	"user-service/mfa"
 	"user-service/models"
+	"user-service/passwords"
+	"user-service/ratelimit"
+	"user-service/sessions"
 	"user-service/utils"
+	"user-service/verification"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
-	DB *gorm.DB
+	DB             *gorm.DB
+	Sessions       *sessions.Store
+	Verify         *verification.Service
+	Resets         *passwords.Resetter
+	ForgotPerEmail *ratelimit.Limiter
+	ResendPerUser  *ratelimit.Limiter
+	Policy         *passwords.Policy
+	MFA            *mfa.Service
+	MFAAttempts    *ratelimit.Limiter
+	Guard          *loginguard.Guard
+}
+
+// PasswordRejected answers a failed policy check: 400 with the broken
+// rules per field, or 500 when the check itself failed
+func PasswordRejected(c *gin.Context, err error) {
+	var perr *passwords.PolicyError
+	if errors.As(err, &perr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": perr.Error(), "fields": perr.Violations})
+		return
+	}
+	log.Printf("passwords: policy check failed: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check password"})
+}
+
+// device names the client of a session, from its User-Agent
+func device(c *gin.Context) string {
+	ua := c.GetHeader("User-Agent")
+	if r := []rune(ua); len(r) > 255 {
+		ua = string(r[:255])
+	}
+	return ua
 }
 
 type RegisterRequest struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" binding:"required,max=100"`
+	Email    string `json:"email" binding:"required,email,max=100"`
+	Password string `json:"password" binding:"required"` // rules in passwords.Policy
 }
 
+// Register - POST /register (public)
+// Creates an unverified account and emails a verification link. The
+// account can sign in right away but cannot subscribe until verified.
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	name := strings.TrimSpace(req.Name)
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
 
 	var existing models.User
-	if err := h.DB.Where("email = ?", strings.ToLower(req.Email)).First(&existing).Error; err == nil {
+	if err := h.DB.Where("email = ?", email).First(&existing).Error; err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "email already exists"})
 		return
 	}
 
-	hash, _ := utils.HashPassword(req.Password)
+	if err := h.Policy.Check("password", req.Password, email, name); err != nil {
+		PasswordRejected(c, err)
+		return
+	}
+
+	hash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		return
+	}
 
 	user := models.User{
-		Name:         req.Name,
-		Email:        strings.ToLower(req.Email),
+		Name:         name,
+		Email:        email,
 		PasswordHash: hash,
 	}
 
 	if err := h.DB.Create(&user).Error; err != nil {
+		log.Printf("register: creating %s failed: %v", email, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
 		return
 	}
+	log.Printf("register: user %d created, verification pending", user.ID)
 
-	c.JSON(http.StatusCreated, gin.H{"id": user.ID, "name": user.Name, "email": user.Email})
+	// the account exists either way; a lost email can be sent again
+	if err := h.Verify.Send(user); err != nil {
+		log.Printf("register: verification email for user %d failed: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":             user.ID,
+		"name":           user.Name,
+		"email":          user.Email,
+		"email_verified": false,
+	})
 }
 
 type LoginRequest struct {
@@ -54,24 +124,176 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// Login - POST /login (public)
+// Repeated failures are slowed down and then locked out per account and
+// per IP, see loginguard.Guard. Every attempt leaves an audit row.
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	ctx := c.Request.Context()
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if !h.guardAllows(c, nil, email) {
+		return
+	}
 
 	var user models.User
-	if err := h.DB.Where("email = ?", strings.ToLower(req.Email)).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	if err := h.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		// same bcrypt cost as a real check, so timing does not tell
+		// unknown addresses apart
+		utils.CheckPasswordHash(req.Password, dummyHash)
+		h.loginFailed(c, nil, email, loginguard.ReasonUnknownUser)
 		return
 	}
 
 	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		h.loginFailed(c, &user, email, loginguard.ReasonBadPassword)
 		return
 	}
 
-	token, _ := utils.GenerateToken(int(user.ID), user.Email)
-	c.JSON(http.StatusOK, gin.H{"token": token, "name": user.Name, "email": user.Email})
+	enabled, err := h.MFA.Enabled(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check two-factor authentication"})
+		return
+	}
+	if enabled {
+		// the password was right; the session, and forgetting the
+		// failures, wait for POST /login/mfa
+		token, exp, err := h.MFA.PendingToken(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start two-factor login"})
+			return
+		}
+		h.Guard.Record(ctx, h.attempt(c, &user.ID, email, true, loginguard.ReasonMFAPending))
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": token, "expires_at": exp})
+		return
+	}
+
+	h.Guard.Record(ctx, h.attempt(c, &user.ID, email, true, loginguard.ReasonOK))
+	h.signIn(c, user)
+}
+
+// a bcrypt hash compared against when the email is unknown
+var dummyHash, _ = utils.HashPassword("login-timing-dummy")
+
+func (h *AuthHandler) attempt(c *gin.Context, userID *uint, email string, success bool, reason string) models.LoginAttempt {
+	return models.LoginAttempt{
+		UserID:    userID,
+		Email:     email,
+		IP:        c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+		Success:   success,
+		Reason:    reason,
+	}
+}
+
+// guardAllows answers 423 or 429 and returns false when the guard slows
+// down or locks out logins of email from the client's IP
+func (h *AuthHandler) guardAllows(c *gin.Context, userID *uint, email string) bool {
+	ctx := c.Request.Context()
+	verdict, err := h.Guard.Check(ctx, email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check login attempts"})
+		return false
+	}
+	if verdict.Wait == 0 {
+		return true
+	}
+	reason := loginguard.ReasonThrottled
+	if verdict.Locked {
+		reason = loginguard.ReasonLocked
+	}
+	h.Guard.Record(ctx, h.attempt(c, userID, email, false, reason))
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(verdict.Wait.Seconds()))))
+	if verdict.Locked {
+		c.JSON(http.StatusLocked, gin.H{"error": "account temporarily locked, try again later or unlock it by email"})
+		return false
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed logins, try again later"})
+	return false
+}
+
+// loginFailed counts and audits a failed login and answers 401
+func (h *AuthHandler) loginFailed(c *gin.Context, user *models.User, email, reason string) {
+	h.countFailure(c, user, email, reason)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+}
+
+// countFailure counts and audits a failed password or second factor. When
+// the failure locks a registered account, its owner gets the unlock email.
+func (h *AuthHandler) countFailure(c *gin.Context, user *models.User, email, reason string) {
+	ctx := c.Request.Context()
+	var userID *uint
+	if user != nil {
+		userID = &user.ID
+	}
+	h.Guard.Record(ctx, h.attempt(c, userID, email, false, reason))
+
+	locked, err := h.Guard.Failure(ctx, email, c.ClientIP())
+	if err != nil {
+		log.Printf("login: counting failure for %s failed: %v", email, err)
+	}
+	if locked && user != nil {
+		log.Printf("login: user %d locked after repeated failures", user.ID)
+		if err := h.Guard.SendUnlock(*user); err != nil {
+			log.Printf("login: unlock email for user %d failed: %v", user.ID, err)
+		}
+	}
+}
+
+// signIn starts a session, forgets the failed logins of the account and
+// answers with the tokens. Roles that need 2FA are listed when the user has
+// them but has not enabled it.
+func (h *AuthHandler) signIn(c *gin.Context, user models.User) {
+	tokens, err := h.Sessions.Create(c.Request.Context(), user, device(c), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	if err := h.Guard.Success(c.Request.Context(), user.Email); err != nil {
+		log.Printf("login: resetting failures of user %d failed: %v", user.ID, err)
+	}
+	out := gin.H{
+		"token":         tokens.AccessToken,
+		"expires_at":    tokens.ExpiresAt,
+		"refresh_token": tokens.RefreshToken,
+		"session_id":    tokens.SessionID,
+		"name":          user.Name,
+		"email":         user.Email,
+	}
+	withheld, err := h.Sessions.WithheldRoles(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Printf("login: checking roles of user %d failed: %v", user.ID, err)
+	} else if len(withheld) > 0 {
+		out["mfa_enrollment_required"] = withheld
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Refresh - POST /token/refresh (public)
+// Rotates the refresh token: the response carries a new access token and a
+// new refresh token, the one sent is spent.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.Sessions.Refresh(c.Request.Context(), req.RefreshToken, device(c), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, sessions.ErrInvalidRefresh) || errors.Is(err, sessions.ErrRefreshReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
 }
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InternalMiddleware guards service-to-service routes with the shared
+// INTERNAL_API_TOKEN, sent in the X-Internal-Token header. Without the
+// variable every internal call is rejected.
+func InternalMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		want := os.Getenv("INTERNAL_API_TOKEN")
+		got := c.GetHeader("X-Internal-Token")
+		if want == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid internal token"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+	"user-service/keys"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS - GET /.well-known/jwks.json (public)
+// Public keys the other services verify access tokens with.
+func JWKS(kr *keys.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{"keys": kr.JWKS()})
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"user-service/loginguard"
+	"user-service/mfa"
+	"user-service/models"
+	"user-service/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP or recovery code
+}
+
+// LoginMFA - POST /login/mfa (public)
+// The second login step for users with 2FA: the mfa_token from /login and a
+// code from the authenticator app or a recovery code. Wrong codes count as
+// failed logins in the login guard, like wrong passwords.
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var req LoginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := h.MFA.ParsePending(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	// six digits are guessable without a cap on attempts
+	if ok, retry := h.MFAAttempts.Allow(strconv.FormatUint(uint64(userID), 10)); !ok {
+		ratelimit.Reject(c, retry)
+		return
+	}
+
+	ctx := c.Request.Context()
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": mfa.ErrInvalidPending.Error()})
+		return
+	}
+	if !h.guardAllows(c, &user.ID, user.Email) {
+		return
+	}
+
+	if err := h.MFA.Verify(ctx, userID, req.Code); err != nil {
+		if errors.Is(err, mfa.ErrInvalidCode) || errors.Is(err, mfa.ErrNotEnabled) {
+			h.countFailure(c, &user, user.Email, loginguard.ReasonBadMFACode)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": mfa.ErrInvalidCode.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check code"})
+		return
+	}
+
+	h.Guard.Record(ctx, h.attempt(c, &user.ID, user.Email, true, loginguard.ReasonOK))
+	h.signIn(c, user)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"user-service/passwords"
+	"user-service/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email,max=100"`
+}
+
+// ForgotPassword - POST /password/forgot (public)
+// Always answers 200 for a well-formed address, registered or not, so the
+// endpoint cannot be used to find out who has an account.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if ok, retry := h.ForgotPerEmail.Allow(email); !ok {
+		ratelimit.Reject(c, retry)
+		return
+	}
+
+	if err := h.Resets.Request(c.Request.Context(), email); err != nil {
+		// logged, not reported: a 500 only for registered addresses would
+		// tell them apart
+		log.Printf("passwords: reset request failed: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "if the email is registered, a reset link has been sent"})
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"` // rules in passwords.Policy
+}
+
+// ResetPassword - POST /password/reset (public)
+// Sets the new password and signs the user out everywhere.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := h.Resets.Reset(c.Request.Context(), req.Token, req.NewPassword)
+	if err != nil {
+		var perr *passwords.PolicyError
+		switch {
+		case errors.Is(err, passwords.ErrInvalidResetToken):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.As(err, &perr):
+			PasswordRejected(c, err)
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		}
+		return
+	}
+	log.Printf("passwords: user %d reset their password, sessions revoked", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "password updated, sign in again"})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"user-service/loginguard"
+	"user-service/models"
+	"user-service/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UnlockRequest struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+// unlockPage is what the link in the unlock email opens. Mail scanners and
+// link previews follow GET links, so the page only asks; the token is spent
+// by the POST its button sends.
+const unlockPage = `<!doctype html>
+<html><head><meta charset="utf-8"><title>Unlock your account</title></head>
+<body>
+<p>Your account was locked after several failed sign-ins.</p>
+<form method="post" action="">
+<input type="hidden" name="token" value="%s">
+<button type="submit">Unlock my account</button>
+</form>
+</body></html>
+`
+
+// ConfirmUnlock - GET /login/unlock?token=... (public)
+// Shows a page that posts the token back; it does not spend it.
+func (h *AuthHandler) ConfirmUnlock(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Data(http.StatusOK, "text/html; charset=utf-8",
+		[]byte(fmt.Sprintf(unlockPage, html.EscapeString(token))))
+}
+
+// UnlockLogin - POST /login/unlock (public)
+// Lifts an account lock with the token from the unlock email, sent as JSON
+// or by the form of ConfirmUnlock. Locks on the IP stay.
+func (h *AuthHandler) UnlockLogin(c *gin.Context) {
+	var req UnlockRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := h.Guard.Unlock(c.Request.Context(), req.Token)
+	if err != nil {
+		if errors.Is(err, loginguard.ErrInvalidUnlockToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlock account"})
+		return
+	}
+	log.Printf("login: user %d unlocked by email", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "account unlocked, you can sign in again"})
+}
+
+type UnlockEmailRequest struct {
+	Email string `json:"email" binding:"required,email,max=100"`
+}
+
+// RequestUnlock - POST /login/unlock/request (public)
+// Sends the unlock email again. Like /password/forgot it answers 200 for
+// any address.
+func (h *AuthHandler) RequestUnlock(c *gin.Context) {
+	var req UnlockEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if ok, retry := h.ForgotPerEmail.Allow("unlock|" + email); !ok {
+		ratelimit.Reject(c, retry)
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Where("email = ?", email).First(&user).Error; err == nil {
+		if err := h.Guard.SendUnlock(user); err != nil {
+			log.Printf("login: unlock email for user %d failed: %v", user.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "if the email is registered, an unlock link has been sent"})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"user-service/models"
+	"user-service/ratelimit"
+	"user-service/utils"
+	"user-service/verification"
+
+	"github.com/gin-gonic/gin"
+	"movie-rest/pkg/auth"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// VerifyEmail - GET /verify-email?token=... and POST /verify-email (public)
+// GET is the link in the email, POST is for clients that read the token
+// from their own page.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if c.Request.Method == http.MethodPost {
+		var req VerifyEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		token = req.Token
+	}
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	user, err := h.Verify.Confirm(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidEmailToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "email verified", "email": user.Email})
+}
+
+// ResendVerification - POST /verify-email/resend (auth required)
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	userID := auth.UserID(c)
+	if ok, retry := h.ResendPerUser.Allow(strconv.FormatUint(uint64(userID), 10)); !ok {
+		ratelimit.Reject(c, retry)
+		return
+	}
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err := h.Verify.Send(user); err != nil {
+		if errors.Is(err, verification.ErrAlreadyVerified) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification email"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "verification email sent"})
+}
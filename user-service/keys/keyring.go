@@ -0,0 +1,297 @@
+package keys
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// kids start with the creation time, so sorting them sorts by age
+const kidTime = "20060102T150405Z"
+
+// Dir is read again on demand (an unknown kid, a JWKS request) at most this
+// often, so tokens with made-up kids cannot keep the disk busy
+const reloadMinInterval = 10 * time.Second
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is one Ed25519 signing key, stored as <ID>.pem in the key directory
+type Key struct {
+	ID      string
+	Private ed25519.PrivateKey
+	Created time.Time
+}
+
+// Keyring holds the keys user-service signs tokens with. The newest key
+// signs; older keys stay published in the JWKS for Grace after they were
+// replaced, so tokens they signed keep verifying until they expire.
+// Replicas of user-service must share Dir; a key another replica created is
+// picked up the first time a token or a JWKS request needs it.
+type Keyring struct {
+	Dir         string
+	RotateEvery time.Duration
+	Grace       time.Duration
+
+	dirMu  sync.Mutex // serializes load and Rotate, which both change Dir or keys
+	mu     sync.RWMutex
+	keys   []Key // newest first
+	loaded time.Time
+}
+
+// NewFromEnv loads the keys in JWT_KEY_DIR and creates the first one when
+// the directory is empty. JWT_KEY_GRACE must be at least the lifetime of
+// the tokens the keys sign.
+func NewFromEnv() (*Keyring, error) {
+	dir := os.Getenv("JWT_KEY_DIR")
+	if dir == "" {
+		dir = "./signing-keys"
+	}
+	rotate := 30 * 24 * time.Hour
+	if d, err := time.ParseDuration(os.Getenv("JWT_KEY_ROTATE")); err == nil && d > 0 {
+		rotate = d
+	}
+	grace := 24 * time.Hour
+	if d, err := time.ParseDuration(os.Getenv("JWT_KEY_GRACE")); err == nil && d > 0 {
+		grace = d
+	}
+	k := &Keyring{Dir: dir, RotateEvery: rotate, Grace: grace}
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+	if err := k.Rotate(time.Now()); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *Keyring) load() error {
+	k.dirMu.Lock()
+	defer k.dirMu.Unlock()
+	files, err := filepath.Glob(filepath.Join(k.Dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	var loaded []Key
+	for _, f := range files {
+		key, err := readKey(f)
+		if err != nil {
+			log.Printf("keys: skipping %s: %v", f, err)
+			continue
+		}
+		loaded = append(loaded, key)
+	}
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].ID > loaded[j].ID })
+
+	k.mu.Lock()
+	k.keys = loaded
+	k.loaded = time.Now()
+	k.mu.Unlock()
+	return nil
+}
+
+// reload reads Dir again unless that happened within reloadMinInterval
+func (k *Keyring) reload() {
+	k.mu.Lock()
+	if time.Since(k.loaded) < reloadMinInterval {
+		k.mu.Unlock()
+		return
+	}
+	// claimed now, so concurrent callers do not read the directory as well
+	k.loaded = time.Now()
+	k.mu.Unlock()
+	if err := k.load(); err != nil {
+		log.Printf("keys: reloading failed: %v", err)
+	}
+}
+
+func readKey(path string) (Key, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return Key{}, errors.New("no PEM block")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return Key{}, err
+	}
+	priv, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return Key{}, errors.New("not an Ed25519 key")
+	}
+	id := strings.TrimSuffix(filepath.Base(path), ".pem")
+	stamp, _, _ := strings.Cut(id, "-")
+	created, err := time.Parse(kidTime, stamp)
+	if err != nil {
+		return Key{}, fmt.Errorf("kid %q does not start with a timestamp", id)
+	}
+	return Key{ID: id, Private: priv, Created: created}, nil
+}
+
+// Rotate creates a new signing key when the current one is older than
+// RotateEvery, and retires replaced keys once their grace period is over
+func (k *Keyring) Rotate(now time.Time) error {
+	k.dirMu.Lock()
+	defer k.dirMu.Unlock()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if len(k.keys) == 0 || now.Sub(k.keys[0].Created) >= k.RotateEvery {
+		key, err := k.generate(now)
+		if err != nil {
+			return err
+		}
+		k.keys = append([]Key{key}, k.keys...)
+		log.Printf("keys: signing with new key %s", key.ID)
+	}
+
+	kept := k.keys[:1]
+	for i := 1; i < len(k.keys); i++ {
+		// a key stopped signing when its successor was created
+		if now.Sub(k.keys[i-1].Created) < k.Grace {
+			kept = append(kept, k.keys[i])
+			continue
+		}
+		if err := os.Remove(filepath.Join(k.Dir, k.keys[i].ID+".pem")); err != nil && !os.IsNotExist(err) {
+			log.Printf("keys: removing retired key %s: %v", k.keys[i].ID, err)
+		}
+	}
+	k.keys = kept
+	return nil
+}
+
+func (k *Keyring) generate(now time.Time) (Key, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Key{}, err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return Key{}, err
+	}
+	id := now.UTC().Format(kidTime) + "-" + hex.EncodeToString(suffix)
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return Key{}, err
+	}
+	if err := os.MkdirAll(k.Dir, 0o700); err != nil {
+		return Key{}, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(k.Dir, id+".pem"), data, 0o600); err != nil {
+		return Key{}, err
+	}
+	return Key{ID: id, Private: priv, Created: now.UTC()}, nil
+}
+
+// Sign signs claims with the current key and names it in the kid header
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	k.mu.RLock()
+	if len(k.keys) == 0 {
+		k.mu.RUnlock()
+		return "", ErrUnknownKey
+	}
+	current := k.keys[0]
+	k.mu.RUnlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = current.ID
+	return token.SignedString(current.Private)
+}
+
+// Keyfunc finds the public key of a token by its kid, for jwt.Parse. An
+// unknown kid may belong to a key another replica just created, so Dir is
+// read again before giving up.
+func (k *Keyring) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if key, ok := k.find(kid); ok {
+		return key, nil
+	}
+	k.reload()
+	if key, ok := k.find(kid); ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (k *Keyring) find(kid string) (ed25519.PublicKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.ID == kid {
+			return key.Private.Public().(ed25519.PublicKey), true
+		}
+	}
+	return nil, false
+}
+
+// JWK is the public half of a key as published at /.well-known/jwks.json
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKS returns the public keys of every key still published, including
+// those other replicas created since the last reload
+func (k *Keyring) JWKS() []JWK {
+	k.reload()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	out := make([]JWK, 0, len(k.keys))
+	for _, key := range k.keys {
+		out = append(out, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key.Private.Public().(ed25519.PublicKey)),
+			Kid: key.ID,
+			Alg: "EdDSA",
+			Use: "sig",
+		})
+	}
+	return out
+}
+
+// Start checks for rotation every hour until ctx is done. Keys are reloaded
+// first, in case another replica rotated already.
+func (k *Keyring) Start(ctx context.Context) {
+	go func() {
+		tick := time.NewTicker(time.Hour)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if err := k.load(); err != nil {
+					log.Printf("keys: reloading failed: %v", err)
+					continue
+				}
+				if err := k.Rotate(time.Now()); err != nil {
+					log.Printf("keys: rotation failed: %v", err)
+				}
+			}
+		}
+	}()
+}
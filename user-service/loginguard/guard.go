@@ -0,0 +1,287 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+	"user-service/mail"
+	"user-service/models"
+	"user-service/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidUnlockToken = errors.New("invalid, used or expired unlock token")
+
+// Audit reasons
+const (
+	ReasonOK          = "ok"
+	ReasonMFAPending  = "mfa_pending"
+	ReasonUnknownUser = "unknown_user"
+	ReasonBadPassword = "bad_password"
+	ReasonBadMFACode  = "bad_mfa_code"
+	ReasonThrottled   = "throttled"
+	ReasonLocked      = "locked"
+)
+
+// Verdict says whether a login may be attempted now
+type Verdict struct {
+	Wait   time.Duration // 0 when the attempt may go ahead
+	Locked bool          // the account is locked, not just slowed down
+}
+
+// Guard slows down and locks out repeated failed logins, per account and
+// per IP. After DelayAfter (IPDelayAfter) failures every further attempt
+// has to wait twice as long as the one before, up to MaxDelay; after
+// AccountLockAfter (IPLockAfter) failures the account (IP) is locked for
+// LockFor. Failures older than Window are forgotten. The IP thresholds are
+// higher, since many users can share an address.
+type Guard struct {
+	Store            Store
+	DB               *gorm.DB // audit rows
+	Window           time.Duration
+	DelayAfter       int
+	IPDelayAfter     int
+	MaxDelay         time.Duration
+	AccountLockAfter int
+	IPLockAfter      int
+	LockFor          time.Duration
+	AuditRetention   time.Duration
+
+	// unlock links
+	Outbox  *mail.Outbox
+	LinkURL string // the token is appended as ?token=
+
+	spend func(ctx context.Context, tokenHash string, now time.Time) (models.User, error) // nil: spendUnlock
+}
+
+// NewFromEnv reads LOGIN_FAILURE_WINDOW (15m), LOGIN_DELAY_AFTER (3),
+// LOGIN_IP_DELAY_AFTER (20), LOGIN_MAX_DELAY (30s), LOGIN_LOCK_AFTER (10),
+// LOGIN_IP_LOCK_AFTER (50), LOGIN_LOCK_DURATION (15m),
+// LOGIN_AUDIT_RETENTION (2160h) and UNLOCK_LOGIN_URL. LOGIN_COUNTER_STORE
+// picks the counter store: "sql" (default) or "memory".
+func NewFromEnv(db *gorm.DB, outbox *mail.Outbox) *Guard {
+	g := &Guard{
+		DB:               db,
+		Window:           envDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		DelayAfter:       envInt("LOGIN_DELAY_AFTER", 3),
+		IPDelayAfter:     envInt("LOGIN_IP_DELAY_AFTER", 20),
+		MaxDelay:         envDuration("LOGIN_MAX_DELAY", 30*time.Second),
+		AccountLockAfter: envInt("LOGIN_LOCK_AFTER", 10),
+		IPLockAfter:      envInt("LOGIN_IP_LOCK_AFTER", 50),
+		LockFor:          envDuration("LOGIN_LOCK_DURATION", 15*time.Minute),
+		AuditRetention:   envDuration("LOGIN_AUDIT_RETENTION", 90*24*time.Hour),
+		Outbox:           outbox,
+		LinkURL:          os.Getenv("UNLOCK_LOGIN_URL"),
+	}
+	if g.LinkURL == "" {
+		g.LinkURL = "http://localhost:8001/login/unlock"
+	}
+	if os.Getenv("LOGIN_COUNTER_STORE") == "memory" {
+		g.Store = NewMemoryStore()
+	} else {
+		g.Store = &SQLStore{DB: db}
+	}
+	return g
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+func envInt(name string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+func accountKey(email string) string { return "acct:" + email }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// Check tells whether email may try to log in from ip now. Accounts are
+// keyed by the email as typed, so unknown addresses are slowed down the
+// same way and the answer reveals nothing about who is registered.
+func (g *Guard) Check(ctx context.Context, email, ip string) (Verdict, error) {
+	now := time.Now()
+	acct, err := g.Store.Get(ctx, accountKey(email))
+	if err != nil {
+		return Verdict{}, err
+	}
+	byIP, err := g.Store.Get(ctx, ipKey(ip))
+	if err != nil {
+		return Verdict{}, err
+	}
+	v := g.verdict(acct, g.DelayAfter, g.AccountLockAfter, now)
+	if w := g.verdict(byIP, g.IPDelayAfter, g.IPLockAfter, now); w.Wait > v.Wait {
+		// an IP lock is reported as a delay; the account itself is fine
+		v = Verdict{Wait: w.Wait}
+	}
+	return v, nil
+}
+
+func (g *Guard) verdict(st State, delayAfter, lockAfter int, now time.Time) Verdict {
+	if st.Failures >= lockAfter {
+		if until := st.LastFailure.Add(g.LockFor); now.Before(until) {
+			return Verdict{Wait: until.Sub(now), Locked: true}
+		}
+		return Verdict{}
+	}
+	if st.Failures < delayAfter || now.Sub(st.LastFailure) > g.Window {
+		return Verdict{}
+	}
+	delay := g.MaxDelay
+	if n := st.Failures - delayAfter; n < 30 {
+		if d := time.Second << n; d < delay {
+			delay = d
+		}
+	}
+	if until := st.LastFailure.Add(delay); now.Before(until) {
+		return Verdict{Wait: until.Sub(now)}
+	}
+	return Verdict{}
+}
+
+// Failure counts a failed login. It reports whether this failure locked
+// the account, so the caller can offer the unlock email.
+func (g *Guard) Failure(ctx context.Context, email, ip string) (bool, error) {
+	now := time.Now()
+	if _, err := g.Store.Add(ctx, ipKey(ip), now, g.Window); err != nil {
+		return false, err
+	}
+	st, err := g.Store.Add(ctx, accountKey(email), now, g.Window)
+	if err != nil {
+		return false, err
+	}
+	return st.Failures == g.AccountLockAfter, nil
+}
+
+// Success forgets the failures of the account once a sign-in is complete,
+// second factor included. Those of the IP stay, or an attacker could clear
+// them by logging into an account of their own.
+func (g *Guard) Success(ctx context.Context, email string) error {
+	return g.Store.Reset(ctx, accountKey(email))
+}
+
+// Record writes the audit row of an attempt. A failed write is logged; it
+// never fails the login.
+func (g *Guard) Record(ctx context.Context, a models.LoginAttempt) {
+	if r := []rune(a.UserAgent); len(r) > 255 {
+		a.UserAgent = string(r[:255])
+	}
+	if err := g.DB.WithContext(ctx).Create(&a).Error; err != nil {
+		log.Printf("loginguard: audit of %s failed: %v", a.Email, err)
+	}
+}
+
+// SendUnlock queues an email with a link that lifts the lock of user's
+// account. The link works once and as long as the lock lasts.
+func (g *Guard) SendUnlock(user models.User) error {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return err
+	}
+	if err := g.DB.Create(&models.LoginUnlock{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(g.LockFor),
+	}).Error; err != nil {
+		return err
+	}
+	link := g.LinkURL + "?token=" + url.QueryEscape(token)
+	g.Outbox.Enqueue(mail.Message{
+		To:      user.Email,
+		Subject: "Your account was locked",
+		Body: fmt.Sprintf("Hi %s,\n\nafter several failed sign-ins your account is locked for %s.\n"+
+			"If it was you, unlock it now with this link:\n\n%s\n\n"+
+			"If it was not you, someone may know your email; consider changing your password.\n",
+			user.Name, g.LockFor, link),
+	})
+	return nil
+}
+
+// Unlock spends an unlock token, lifts the lock it was sent for and
+// returns the user. The other unlock tokens of the user are spent too, so
+// an old link cannot lift a later lock.
+func (g *Guard) Unlock(ctx context.Context, token string) (uint, error) {
+	spend := g.spend
+	if spend == nil {
+		spend = g.spendUnlock
+	}
+	user, err := spend(ctx, utils.HashToken(token), time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, g.Store.Reset(ctx, accountKey(user.Email))
+}
+
+// spendUnlock marks the open unlock tokens of the user tokenHash belongs to
+// as used and returns that user
+func (g *Guard) spendUnlock(ctx context.Context, tokenHash string, now time.Time) (models.User, error) {
+	var user models.User
+	err := g.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var lu models.LoginUnlock
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+			First(&lu).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidUnlockToken
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, lu.UserID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.LoginUnlock{}).
+			Where("user_id = ? AND used_at IS NULL", lu.UserID).
+			Update("used_at", now).Error
+	})
+	return user, err
+}
+
+// Purge forgets stale counters, spent or expired unlock tokens and audit
+// rows past the retention
+func (g *Guard) Purge(ctx context.Context) error {
+	now := time.Now()
+	horizon := g.Window
+	if g.LockFor > horizon {
+		horizon = g.LockFor
+	}
+	if err := g.Store.Purge(ctx, now.Add(-horizon)); err != nil {
+		return err
+	}
+	if err := g.DB.WithContext(ctx).Where("expires_at < ? OR used_at IS NOT NULL", now).
+		Delete(&models.LoginUnlock{}).Error; err != nil {
+		return err
+	}
+	return g.DB.WithContext(ctx).Where("created_at < ?", now.Add(-g.AuditRetention)).
+		Delete(&models.LoginAttempt{}).Error
+}
+
+// Start purges every hour until ctx is done
+func (g *Guard) Start(ctx context.Context) {
+	go func() {
+		tick := time.NewTicker(time.Hour)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if err := g.Purge(ctx); err != nil {
+					log.Printf("loginguard: purge failed: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,218 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"user-service/models"
+	"user-service/utils"
+)
+
+const (
+	testEmail = "user@example.com"
+	testIP    = "203.0.113.7"
+)
+
+func newTestGuard() *Guard {
+	return &Guard{
+		Store:            NewMemoryStore(),
+		Window:           15 * time.Minute,
+		DelayAfter:       3,
+		IPDelayAfter:     20,
+		MaxDelay:         30 * time.Second,
+		AccountLockAfter: 5,
+		IPLockAfter:      50,
+		LockFor:          15 * time.Minute,
+	}
+}
+
+// fail counts n failed logins of email from ip
+func fail(t *testing.T, g *Guard, email, ip string, n int) (locked bool) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		var err error
+		if locked, err = g.Failure(context.Background(), email, ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return locked
+}
+
+func failures(t *testing.T, g *Guard, key string) int {
+	t.Helper()
+	st, err := g.Store.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st.Failures
+}
+
+func check(t *testing.T, g *Guard) Verdict {
+	t.Helper()
+	v, err := g.Check(context.Background(), testEmail, testIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestGuardVerdict(t *testing.T) {
+	g := newTestGuard()
+	last := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		failures int
+		since    time.Duration // from the last failure to now
+		want     Verdict
+	}{
+		{name: "no failures", since: time.Hour},
+		{name: "below the delay", failures: 2},
+		{name: "first delay", failures: 3, want: Verdict{Wait: time.Second}},
+		{name: "delay doubles", failures: 5, since: time.Second, want: Verdict{Wait: 3 * time.Second}},
+		{name: "delay over", failures: 3, since: 2 * time.Second},
+		{name: "delay capped", failures: 9, want: Verdict{Wait: 30 * time.Second}},
+		{name: "failures out of the window", failures: 9, since: 16 * time.Minute},
+		{name: "locked", failures: 10, since: time.Minute, want: Verdict{Wait: 14 * time.Minute, Locked: true}},
+		{name: "lock over", failures: 10, since: 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := State{Failures: tt.failures, LastFailure: last}
+			if got := g.verdict(st, 3, 10, last.Add(tt.since)); got != tt.want {
+				t.Fatalf("verdict = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuardFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int
+		wantLocked bool // returned by the last Failure
+		wantCheck  bool // Check reports the account locked
+	}{
+		{name: "below the lock", failures: 4},
+		{name: "locking failure", failures: 5, wantLocked: true, wantCheck: true},
+		{name: "after the lock", failures: 6, wantCheck: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGuard()
+			if got := fail(t, g, testEmail, testIP, tt.failures); got != tt.wantLocked {
+				t.Fatalf("Failure = %v, want %v", got, tt.wantLocked)
+			}
+			if got := check(t, g).Locked; got != tt.wantCheck {
+				t.Fatalf("Check locked = %v, want %v", got, tt.wantCheck)
+			}
+			if got := failures(t, g, ipKey(testIP)); got != tt.failures {
+				t.Fatalf("IP failures = %d, want %d", got, tt.failures)
+			}
+		})
+	}
+}
+
+// An IP past its own lock is slowed down, but no account is reported locked
+func TestGuardFailureByIP(t *testing.T) {
+	g := newTestGuard()
+	for i := 0; i < g.IPLockAfter; i++ {
+		fail(t, g, fmt.Sprintf("user%d@example.com", i), testIP, 1)
+	}
+	v := check(t, g)
+	if v.Locked || v.Wait <= 0 {
+		t.Fatalf("Check = %+v, want a delay without lock", v)
+	}
+}
+
+func TestGuardSuccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+	}{
+		{name: "no failures"},
+		{name: "delayed", failures: 3},
+		{name: "locked", failures: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGuard()
+			fail(t, g, testEmail, testIP, tt.failures)
+			if err := g.Success(context.Background(), testEmail); err != nil {
+				t.Fatal(err)
+			}
+			if got := failures(t, g, accountKey(testEmail)); got != 0 {
+				t.Fatalf("account failures = %d, want 0", got)
+			}
+			if got := failures(t, g, ipKey(testIP)); got != tt.failures {
+				t.Fatalf("IP failures = %d, want %d", got, tt.failures)
+			}
+		})
+	}
+}
+
+// spendTokens stands in for the login_unlocks table: each token works once
+func spendTokens(tokens map[string]models.User) func(context.Context, string, time.Time) (models.User, error) {
+	byHash := map[string]models.User{}
+	for tok, u := range tokens {
+		byHash[utils.HashToken(tok)] = u
+	}
+	return func(ctx context.Context, tokenHash string, now time.Time) (models.User, error) {
+		u, ok := byHash[tokenHash]
+		if !ok {
+			return models.User{}, ErrInvalidUnlockToken
+		}
+		delete(byHash, tokenHash)
+		return u, nil
+	}
+}
+
+func TestGuardUnlock(t *testing.T) {
+	user := models.User{ID: 7, Email: testEmail}
+	errDB := errors.New("db down")
+
+	tests := []struct {
+		name       string
+		spend      func(context.Context, string, time.Time) (models.User, error)
+		tokens     []string // spent in order, the last one is checked
+		wantErr    error
+		wantLocked bool
+	}{
+		{name: "valid token", spend: spendTokens(map[string]models.User{"tok": user}), tokens: []string{"tok"}},
+		{name: "unknown token", spend: spendTokens(map[string]models.User{"tok": user}), tokens: []string{"other"},
+			wantErr: ErrInvalidUnlockToken, wantLocked: true},
+		{name: "token used twice", spend: spendTokens(map[string]models.User{"tok": user}), tokens: []string{"tok", "tok"},
+			wantErr: ErrInvalidUnlockToken},
+		{name: "lookup failing", spend: func(context.Context, string, time.Time) (models.User, error) {
+			return models.User{}, errDB
+		}, tokens: []string{"tok"}, wantErr: errDB, wantLocked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGuard()
+			g.spend = tt.spend
+			fail(t, g, testEmail, testIP, g.AccountLockAfter)
+
+			var id uint
+			var err error
+			for _, tok := range tt.tokens {
+				id, err = g.Unlock(context.Background(), tok)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || id != user.ID {
+				t.Fatalf("Unlock = %d, %v", id, err)
+			}
+			if got := check(t, g).Locked; got != tt.wantLocked {
+				t.Fatalf("Check locked = %v, want %v", got, tt.wantLocked)
+			}
+			if got := failures(t, g, ipKey(testIP)); got != g.AccountLockAfter {
+				t.Fatalf("IP failures = %d, want %d", got, g.AccountLockAfter)
+			}
+		})
+	}
+}
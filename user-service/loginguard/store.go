@@ -0,0 +1,110 @@
+package loginguard
+
+import (
+	"context"
+	"sync"
+	"time"
+	"user-service/models"
+
+	"gorm.io/gorm"
+)
+
+// State is the failed logins counted under one key
+type State struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Store keeps the failure counters. Replicas of user-service must share
+// one, or each would allow the full number of attempts. SQLStore works on
+// the shared Postgres database; a Redis store would implement Add with
+// INCR and EXPIRE.
+type Store interface {
+	// Get returns the counter of key, zero when there is none
+	Get(ctx context.Context, key string) (State, error)
+	// Add counts a failure at now. A counter whose last failure is older
+	// than window starts over.
+	Add(ctx context.Context, key string, now time.Time, window time.Duration) (State, error)
+	// Reset forgets key
+	Reset(ctx context.Context, key string) error
+	// Purge forgets counters whose last failure is before t
+	Purge(ctx context.Context, t time.Time) error
+}
+
+// MemoryStore keeps counters in the process, for tests and single replicas
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]State
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: map[string]State{}}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[key], nil
+}
+
+func (m *MemoryStore) Add(ctx context.Context, key string, now time.Time, window time.Duration) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.counters[key]
+	if now.Sub(st.LastFailure) > window {
+		st.Failures = 0
+	}
+	st.Failures++
+	st.LastFailure = now
+	m.counters[key] = st
+	return st, nil
+}
+
+func (m *MemoryStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.counters, key)
+	return nil
+}
+
+func (m *MemoryStore) Purge(ctx context.Context, t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, st := range m.counters {
+		if st.LastFailure.Before(t) {
+			delete(m.counters, k)
+		}
+	}
+	return nil
+}
+
+// SQLStore keeps counters in the login_counters table. Add is a single
+// upsert, so concurrent failures on several replicas all count.
+type SQLStore struct {
+	DB *gorm.DB
+}
+
+func (s *SQLStore) Get(ctx context.Context, key string) (State, error) {
+	var row models.LoginCounter
+	err := s.DB.WithContext(ctx).Where("key = ?", key).Limit(1).Find(&row).Error
+	return State{Failures: row.Failures, LastFailure: row.LastFailure}, err
+}
+
+func (s *SQLStore) Add(ctx context.Context, key string, now time.Time, window time.Duration) (State, error) {
+	var st State
+	err := s.DB.WithContext(ctx).Raw(`
+		INSERT INTO login_counters (key, failures, last_failure) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_counters.last_failure < ? THEN 1 ELSE login_counters.failures + 1 END,
+			last_failure = EXCLUDED.last_failure
+		RETURNING failures, last_failure`, key, now, now.Add(-window)).Scan(&st).Error
+	return st, err
+}
+
+func (s *SQLStore) Reset(ctx context.Context, key string) error {
+	return s.DB.WithContext(ctx).Where("key = ?", key).Delete(&models.LoginCounter{}).Error
+}
+
+func (s *SQLStore) Purge(ctx context.Context, t time.Time) error {
+	return s.DB.WithContext(ctx).Where("last_failure < ?", t).Delete(&models.LoginCounter{}).Error
+}
@@ -0,0 +1,70 @@
+package mail
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Outbox sends emails in the background, so a slow or failing mail server
+// never holds up a request. Emails still queued at shutdown are sent
+// before Start's channel closes.
+type Outbox struct {
+	Mailer   Mailer
+	Attempts int
+
+	queue chan Message
+}
+
+func NewOutbox(m Mailer) *Outbox {
+	return &Outbox{Mailer: m, Attempts: 3, queue: make(chan Message, 256)}
+}
+
+// Enqueue queues msg. It never blocks: when the queue is full the email is
+// dropped and logged, the user can ask for it again.
+func (o *Outbox) Enqueue(msg Message) {
+	select {
+	case o.queue <- msg:
+	default:
+		log.Printf("mail: queue full, dropping %q to %s", msg.Subject, msg.To)
+	}
+}
+
+// Start sends queued emails until ctx is cancelled, then drains the queue.
+// The returned channel closes when the last email was handled.
+func (o *Outbox) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case msg := <-o.queue:
+				o.send(msg)
+			case <-ctx.Done():
+				for {
+					select {
+					case msg := <-o.queue:
+						o.send(msg)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return done
+}
+
+func (o *Outbox) send(msg Message) {
+	var err error
+	for attempt := 1; attempt <= o.Attempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err = o.Mailer.Send(ctx, msg)
+		cancel()
+		if err == nil {
+			return
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	log.Printf("mail: sending %q to %s failed: %v", msg.Subject, msg.To, err)
+}
@@ -1,24 +1,86 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"user-service/connection"
 	"user-service/controllers"
 	"user-service/handlers"
+	"user-service/keys"
+	"user-service/loginguard"
+	"user-service/mail"
+	"user-service/mfa"
+	"user-service/models"
+	"user-service/movieclient"
+	"user-service/passwords"
+	"user-service/playback"
+	"user-service/ratelimit"
+	"user-service/sessions"
+	"user-service/verification"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"movie-rest/pkg/auth"
 )
 
 func main() {
 	// Load .env
 	_ = godotenv.Load(".env")
 
+	// cancelled on SIGINT/SIGTERM so buffered heartbeats can be flushed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Connect to DB
 	db := connection.Connect()
 
-	auth := handlers.AuthHandler{DB: db}
+	// access tokens are signed with rotating Ed25519 keys, published at
+	// /.well-known/jwks.json for the other services
+	kr, err := keys.NewFromEnv()
+	if err != nil {
+		log.Fatal("Failed to load signing keys:", err)
+	}
+	kr.Start(ctx)
+	verifier := auth.NewVerifier(kr.Keyfunc, auth.SQLDenylist(db))
+	store := sessions.NewStore(db, kr)
+	if kr.Grace < store.AccessTTL {
+		log.Fatal("JWT_KEY_GRACE must not be shorter than ACCESS_TOKEN_TTL")
+	}
+	store.Start(ctx)
+	outbox := mail.NewOutbox(mail.NewFromEnv())
+	verify, err := verification.NewFromEnv(db, outbox)
+	if err != nil {
+		log.Fatal("Failed to set up email verification:", err)
+	}
+	policy := passwords.NewPolicyFromEnv()
+	resets := passwords.NewResetterFromEnv(db, outbox, policy)
+	resets.Start(ctx)
+	// password recovery and unlock emails: 3 per address and 10 requests
+	// per IP an hour
+	forgotPerEmail := ratelimit.New(3, time.Hour)
+	forgotPerEmail.Start(ctx)
+	passwordPerIP := ratelimit.New(10, time.Hour)
+	passwordPerIP.Start(ctx)
+	// verification emails on request: 3 per user an hour
+	resendPerUser := ratelimit.New(3, time.Hour)
+	resendPerUser.Start(ctx)
+	mfaSvc, err := mfa.NewFromEnv(db, kr)
+	if err != nil {
+		log.Fatal("Failed to set up two-factor authentication:", err)
+	}
+	// 5 code guesses per user every 5 minutes
+	mfaAttempts := ratelimit.New(5, 5*time.Minute)
+	mfaAttempts.Start(ctx)
+	guard := loginguard.NewFromEnv(db, outbox)
+	guard.Start(ctx)
+	ah := handlers.AuthHandler{DB: db, Sessions: store, Verify: verify, Resets: resets, ForgotPerEmail: forgotPerEmail,
+		ResendPerUser: resendPerUser, Policy: policy, MFA: mfaSvc, MFAAttempts: mfaAttempts, Guard: guard}
 	r := gin.Default()
 
 	// ✅ Setup CORS
@@ -31,27 +93,118 @@ func main() {
 	}))
 
 	// Routes
-	r.POST("/register", auth.Register)
-	r.POST("/login", auth.Login)
-	r.POST("/logout", handlers.AuthMiddleware(), controllers.Logout)
+	r.GET("/.well-known/jwks.json", handlers.JWKS(kr))
+	r.POST("/register", ah.Register)
+	r.POST("/login", ah.Login)
+	r.POST("/login/mfa", ah.LoginMFA)
+	r.GET("/login/unlock", ah.ConfirmUnlock)
+	r.POST("/login/unlock", ah.UnlockLogin)
+	r.POST("/login/unlock/request", ratelimit.PerIP(passwordPerIP), ah.RequestUnlock)
+	r.POST("/token/refresh", ah.Refresh)
+	r.POST("/password/forgot", ratelimit.PerIP(passwordPerIP), ah.ForgotPassword)
+	r.POST("/password/reset", ratelimit.PerIP(passwordPerIP), ah.ResetPassword)
+	r.GET("/verify-email", ah.VerifyEmail)
+	r.POST("/verify-email", ah.VerifyEmail)
+	r.POST("/verify-email/resend", verifier.Required(), ah.ResendVerification)
+	r.POST("/logout", verifier.Required(), controllers.Logout(store))
 	sc := controllers.SubscriptionController{DB: db}
-	wc := controllers.WatchlistController{DB: db}
+	wc := controllers.WatchlistController{DB: db, Movies: movieclient.NewFromEnv()}
+	sgc := controllers.SignalsController{DB: db}
+	lc := controllers.ListController{DB: db, Movies: wc.Movies}
+
+	// the tracker outlives the HTTP server so heartbeats of draining
+	// requests still make it into the final flush
+	trackerCtx, stopTracker := context.WithCancel(context.Background())
+	tracker := playback.NewTracker(db, wc.Movies)
+	trackerDone := tracker.Start(trackerCtx)
+	// like the tracker, the outbox drains after the server stopped
+	outboxDone := outbox.Start(trackerCtx)
+	pc := controllers.PlaybackController{DB: db, Tracker: tracker, Movies: wc.Movies}
+	hc := controllers.HistoryController{DB: db, Tracker: tracker, Movies: wc.Movies}
+	ssc := controllers.SessionController{Store: store}
+	ic := controllers.InternalController{DB: db, Playback: tracker}
+	rc := controllers.RoleController{DB: db, Sessions: store}
+	mfc := controllers.MFAController{DB: db, MFA: mfaSvc, Sessions: store, Attempts: mfaAttempts}
 
 	protected := r.Group("/")
-	protected.Use(handlers.AuthMiddleware())
+	protected.Use(verifier.Required())
 	{
 		protected.GET("/profile", controllers.GetProfile(db))
-		protected.PATCH("/profile", controllers.UpdateProfile(db))
+		protected.PATCH("/profile", controllers.UpdateProfile(db, verify))
 		protected.PATCH("/subscribe", sc.UpdateUserSubscription)
 
-		protected.PATCH("/profile/password", controllers.ChangePassword(db))
+		protected.PATCH("/profile/password", controllers.ChangePassword(db, policy))
+
+		protected.GET("/me/mfa", mfc.GetMFA)
+		protected.POST("/me/mfa/enroll", mfc.EnrollMFA)
+		protected.POST("/me/mfa/confirm", mfc.ConfirmMFA)
+		protected.POST("/me/mfa/recovery-codes", mfc.RegenerateRecoveryCodes)
+		protected.DELETE("/me/mfa", mfc.DisableMFA)
+
+		protected.GET("/me/sessions", ssc.GetSessions)
+		protected.DELETE("/me/sessions/:id", ssc.DeleteSession)
 
 		protected.POST("/profile/watchlist", wc.AddToWatchlist)
 		protected.GET("/profile/watchlist", wc.GetWatchlist)
+		protected.GET("/profile/watchlist/ids", wc.GetWatchlistIDs)
 		protected.DELETE("/profile/watchlist/:movieId", wc.RemoveFromWatchlist)
+
+		protected.GET("/profile/signals", sgc.GetSignals)
+
+		protected.GET("/lists", lc.GetMyLists)
+		protected.POST("/lists", lc.CreateList)
+		protected.GET("/lists/:id", lc.GetList)
+		protected.PATCH("/lists/:id", lc.UpdateList)
+		protected.DELETE("/lists/:id", lc.DeleteList)
+		protected.POST("/lists/:id/items", lc.AddItem)
+		protected.PATCH("/lists/:id/items/:movieId", lc.UpdateItem)
+		protected.DELETE("/lists/:id/items/:movieId", lc.RemoveItem)
+
+		protected.POST("/me/progress", pc.RecordProgress)
+		protected.GET("/me/continue-watching", pc.ContinueWatching)
+
+		protected.GET("/me/history", hc.GetHistory)
+		protected.GET("/me/history/export", hc.ExportHistory)
+		protected.DELETE("/me/history", hc.ClearHistory)
+		protected.DELETE("/me/history/:id", hc.DeleteHistoryEntry)
+	}
+
+	// role management, admins only
+	admin := r.Group("/admin")
+	admin.Use(verifier.Required(), auth.RequirePermission(models.PermRolesManage))
+	{
+		admin.GET("/users/:id/roles", rc.GetRoles)
+		admin.POST("/users/:id/roles", rc.GrantRole)
+		admin.DELETE("/users/:id/roles/:role", rc.RevokeRole)
 	}
 
+	// public, no token needed
+	r.GET("/shared/lists/:slug", lc.GetSharedList)
+	r.GET("/users/:id/lists", lc.GetUserLists)
 
-	log.Println("User service running on :8001")
-	r.Run(":8001")
+	// called by the other services, never by clients
+	internal := r.Group("/internal")
+	internal.Use(handlers.InternalMiddleware())
+	{
+		internal.DELETE("/movies/:movieId", ic.ForgetMovie)
+	}
+
+	srv := &http.Server{Addr: ":8001", Handler: r}
+	go func() {
+		log.Println("User service running on :8001")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down user service")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("server shutdown: %v", err)
+	}
+	stopTracker()
+	<-trackerDone
+	<-outboxDone
 }
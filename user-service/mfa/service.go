@@ -0,0 +1,272 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"user-service/keys"
+	"user-service/models"
+	"user-service/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"movie-rest/pkg/auth"
+)
+
+// the aud of mfa_pending tokens; no access token verifier accepts it
+const pendingAudience = "mfa_pending"
+
+// how many recovery codes a user gets
+const recoveryCodes = 10
+
+var (
+	ErrAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrNotEnrolled    = errors.New("two-factor authentication is not being set up")
+	ErrNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidCode    = errors.New("invalid code")
+	ErrInvalidPending = errors.New("invalid or expired mfa token")
+)
+
+// Service enrolls users in TOTP two-factor authentication and checks their
+// codes
+type Service struct {
+	DB         *gorm.DB
+	Keys       *keys.Keyring
+	Issuer     string        // the account name shown in authenticator apps
+	PendingTTL time.Duration // how long the second login step may take
+
+	sealer *sealer
+}
+
+// NewFromEnv reads MFA_SECRET_KEY, which encrypts the TOTP secrets,
+// MFA_ISSUER (Movie REST) and MFA_PENDING_TTL (5m)
+func NewFromEnv(db *gorm.DB, kr *keys.Keyring) (*Service, error) {
+	key := os.Getenv("MFA_SECRET_KEY")
+	if len(key) < 32 {
+		return nil, errors.New("MFA_SECRET_KEY must be at least 32 characters")
+	}
+	s, err := newSealer(key)
+	if err != nil {
+		return nil, err
+	}
+	issuer := os.Getenv("MFA_ISSUER")
+	if issuer == "" {
+		issuer = "Movie REST"
+	}
+	ttl := 5 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("MFA_PENDING_TTL")); err == nil && d > 0 {
+		ttl = d
+	}
+	return &Service{DB: db, Keys: kr, Issuer: issuer, PendingTTL: ttl, sealer: s}, nil
+}
+
+// Enabled reports whether the user confirmed an enrollment
+func (s *Service) Enabled(ctx context.Context, userID uint) (bool, error) {
+	var n int64
+	err := s.DB.WithContext(ctx).Model(&models.UserMFA{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).Count(&n).Error
+	return n > 0, err
+}
+
+// RecoveryCodesLeft counts the unused recovery codes of a user
+func (s *Service) RecoveryCodesLeft(ctx context.Context, userID uint) (int64, error) {
+	var n int64
+	err := s.DB.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).Count(&n).Error
+	return n, err
+}
+
+// Enroll starts (or restarts) an enrollment with a new secret. It returns
+// the secret and the otpauth:// URI to show as a QR code; nothing changes
+// for the user until Confirm.
+func (s *Service) Enroll(ctx context.Context, user models.User) (string, string, error) {
+	enabled, err := s.Enabled(ctx, user.ID)
+	if err != nil {
+		return "", "", err
+	}
+	if enabled {
+		return "", "", ErrAlreadyEnabled
+	}
+	secret, err := NewSecret()
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := s.sealer.seal(secret)
+	if err != nil {
+		return "", "", err
+	}
+	row := models.UserMFA{UserID: user.ID, Secret: sealed}
+	if err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "confirmed_at", "last_step", "created_at"}),
+	}).Create(&row).Error; err != nil {
+		return "", "", err
+	}
+	return secret, URI(s.Issuer, user.Email, secret), nil
+}
+
+// Confirm finishes an enrollment with a first code from the app and returns
+// the recovery codes, which are shown this once
+func (s *Service) Confirm(ctx context.Context, userID uint, input string) ([]string, error) {
+	var codes []string
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row models.UserMFA
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND confirmed_at IS NULL", userID).First(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotEnrolled
+		}
+		if err != nil {
+			return err
+		}
+		secret, err := s.sealer.open(row.Secret)
+		if err != nil {
+			return err
+		}
+		step, ok := Validate(secret, input, time.Now())
+		if !ok {
+			return ErrInvalidCode
+		}
+		if err := tx.Model(&row).Updates(map[string]interface{}{
+			"confirmed_at": time.Now(),
+			"last_step":    step,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = newRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of an enabled user
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, userID uint) ([]string, error) {
+	var codes []string
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = newRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+func newRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodes)
+	rows := make([]models.RecoveryCode, 0, recoveryCodes)
+	for i := 0; i < recoveryCodes; i++ {
+		raw, err := NewSecret()
+		if err != nil {
+			return nil, err
+		}
+		// xxxxx-xxxxx, 50 bits
+		code := strings.ToLower(raw[:5] + "-" + raw[5:10])
+		codes = append(codes, code)
+		rows = append(rows, models.RecoveryCode{UserID: userID, CodeHash: utils.HashToken(normalizeRecovery(code))})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecovery(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
+
+// Verify checks a TOTP code or a recovery code of an enabled user. Each
+// TOTP code and each recovery code works once.
+func (s *Service) Verify(ctx context.Context, userID uint, input string) error {
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row models.UserMFA
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND confirmed_at IS NOT NULL", userID).First(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotEnabled
+		}
+		if err != nil {
+			return err
+		}
+
+		secret, err := s.sealer.open(row.Secret)
+		if err != nil {
+			return err
+		}
+		if step, ok := Validate(secret, input, time.Now()); ok {
+			if step <= row.LastStep {
+				return ErrInvalidCode
+			}
+			return tx.Model(&row).Update("last_step", step).Error
+		}
+
+		res := tx.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashToken(normalizeRecovery(input))).
+			Update("used_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidCode
+		}
+		return nil
+	})
+}
+
+// Disable removes the enrollment and the recovery codes
+func (s *Service) Disable(ctx context.Context, userID uint) error {
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ?", userID).Delete(&models.UserMFA{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotEnabled
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// PendingToken is handed out after the password step of a login. It only
+// proves the password and is good for nothing but POST /login/mfa.
+func (s *Service) PendingToken(userID uint) (string, time.Time, error) {
+	jti, err := utils.RandomToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	exp := now.Add(s.PendingTTL)
+	token, err := s.Keys.Sign(jwt.RegisteredClaims{
+		ID:        jti,
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		Issuer:    auth.Issuer(),
+		Audience:  jwt.ClaimStrings{pendingAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(exp),
+	})
+	return token, exp, err
+}
+
+// ParsePending returns the user a pending token was issued to
+func (s *Service) ParsePending(token string) (uint, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, s.Keys.Keyfunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(auth.Issuer()),
+		jwt.WithAudience(pendingAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return 0, ErrInvalidPending
+	}
+	id, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidPending
+	}
+	return uint(id), nil
+}
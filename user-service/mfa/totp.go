@@ -0,0 +1,77 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238). They are what authenticator apps assume, so
+// they are not configurable.
+const (
+	period = 30 * time.Second
+	digits = 6
+	skew   = 1 // steps accepted either side of now, for clock drift
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random 160-bit secret, base32 encoded
+func NewSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(b), nil
+}
+
+// URI is the otpauth:// URI authenticator apps import, usually as a QR code
+func URI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(digits))
+	v.Set("period", fmt.Sprint(int(period.Seconds())))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// code is the TOTP of secret for one time step
+func code(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, n%1000000)
+}
+
+// Validate checks a code against secret at now and returns the time step
+// it belongs to. Callers reject steps they saw before, so a code works once.
+func Validate(secret, input string, now time.Time) (int64, bool) {
+	input = strings.ReplaceAll(strings.TrimSpace(input), " ", "")
+	if len(input) != digits {
+		return 0, false
+	}
+	key, err := b32.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	step := now.Unix() / int64(period.Seconds())
+	for d := int64(-skew); d <= skew; d++ {
+		if subtle.ConstantTimeCompare([]byte(code(key, step+d)), []byte(input)) == 1 {
+			return step + d, true
+		}
+	}
+	return 0, false
+}
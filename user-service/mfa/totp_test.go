@@ -0,0 +1,46 @@
+package mfa
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		input    string
+		unix     int64
+		wantStep int64
+		wantOK   bool
+	}{
+		// RFC 6238 appendix B, SHA-1; the codes are the last 6 of its 8 digits
+		{name: "rfc 59", secret: rfcSecret, input: "287082", unix: 59, wantStep: 1, wantOK: true},
+		{name: "rfc 1111111109", secret: rfcSecret, input: "081804", unix: 1111111109, wantStep: 37037036, wantOK: true},
+		{name: "rfc 1111111111", secret: rfcSecret, input: "050471", unix: 1111111111, wantStep: 37037037, wantOK: true},
+		{name: "rfc 1234567890", secret: rfcSecret, input: "005924", unix: 1234567890, wantStep: 41152263, wantOK: true},
+		{name: "rfc 2000000000", secret: rfcSecret, input: "279037", unix: 2000000000, wantStep: 66666666, wantOK: true},
+		{name: "rfc 20000000000", secret: rfcSecret, input: "353130", unix: 20000000000, wantStep: 666666666, wantOK: true},
+
+		{name: "previous step", secret: rfcSecret, input: "287082", unix: 89, wantStep: 1, wantOK: true},
+		{name: "next step", secret: rfcSecret, input: "050471", unix: 1111111109, wantStep: 37037037, wantOK: true},
+		{name: "two steps late", secret: rfcSecret, input: "287082", unix: 119},
+		{name: "spaces", secret: rfcSecret, input: " 287 082 ", unix: 59, wantStep: 1, wantOK: true},
+		{name: "lowercase secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", input: "287082", unix: 59, wantStep: 1, wantOK: true},
+		{name: "wrong code", secret: rfcSecret, input: "287083", unix: 59},
+		{name: "8 digits", secret: rfcSecret, input: "94287082", unix: 59},
+		{name: "too short", secret: rfcSecret, input: "28708", unix: 59},
+		{name: "bad secret", secret: "not base32!", input: "287082", unix: 59},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(tt.secret, tt.input, time.Unix(tt.unix, 0))
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Fatalf("Validate = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// List privacy levels
+const (
+	PrivacyPrivate  = "private"  // only the owner
+	PrivacyUnlisted = "unlisted" // anyone with the slug
+	PrivacyPublic   = "public"   // anyone with the slug, and shown on the owner's profile
+)
+
+// DefaultListTitle is the title of the list created for /profile/watchlist
+const DefaultListTitle = "Watchlist"
+
+// List is a named, ordered list of movies. Every user has at most one
+// default list, which is the watchlist served by /profile/watchlist.
+type List struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	Title       string    `gorm:"type:varchar(100);not null" json:"title"`
+	Description string    `gorm:"type:text" json:"description"`
+	Privacy     string    `gorm:"type:varchar(10);not null;default:'private'" json:"privacy"`
+	Slug        string    `gorm:"type:varchar(32);uniqueIndex;not null" json:"slug"`
+	IsDefault   bool      `gorm:"not null;default:false" json:"is_default"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ListItem is one movie in a list. Position orders the items (1 = first).
+type ListItem struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ListID    uint      `gorm:"uniqueIndex:idx_list_items_list_movie;index:idx_list_items_position,priority:1;not null" json:"list_id"`
+	MovieID   uint      `gorm:"uniqueIndex:idx_list_items_list_movie;index;not null" json:"movie_id"`
+	Position  int       `gorm:"index:idx_list_items_position,priority:2;not null" json:"position"`
+	Note      string    `gorm:"type:varchar(500)" json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// LoginAttempt is the audit row of one login attempt, kept for
+// LOGIN_AUDIT_RETENTION. UserID is nil when the email matched no account.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    *uint     `gorm:"index" json:"user_id"`
+	Email     string    `gorm:"type:varchar(100);index" json:"email"`
+	IP        string    `gorm:"type:varchar(45);index" json:"ip"`
+	UserAgent string    `gorm:"type:varchar(255)" json:"user_agent"`
+	Success   bool      `json:"success"`
+	Reason    string    `gorm:"type:varchar(32)" json:"reason"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// LoginUnlock is a token emailed when an account gets locked. Only the
+// hash is stored; a token works once and until ExpiresAt.
+type LoginUnlock struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LoginCounter counts recent failed logins of one account or IP, for the
+// SQL counter store
+type LoginCounter struct {
+	Key         string    `gorm:"primaryKey;type:varchar(160)" json:"key"`
+	Failures    int       `gorm:"not null" json:"failures"`
+	LastFailure time.Time `gorm:"index" json:"last_failure"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// UserMFA is a user's TOTP enrollment. It counts only once ConfirmedAt is
+// set; an unconfirmed row is an enrollment in progress.
+type UserMFA struct {
+	UserID      uint       `gorm:"primaryKey" json:"user_id"`
+	Secret      string     `gorm:"type:text;not null" json:"-"` // AES-GCM sealed
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	LastStep    int64      `json:"-"` // time step of the last accepted code, against replays
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// RecoveryCode is a one-time code that stands in for a TOTP code. Only the
+// hash is stored.
+type RecoveryCode struct {
+	ID       uint       `gorm:"primaryKey" json:"id"`
+	UserID   uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash string     `gorm:"type:varchar(64);not null" json:"-"`
+	UsedAt   *time.Time `json:"used_at"`
+}
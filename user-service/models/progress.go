@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// WatchProgress is the last known playback position of a user in a movie
+type WatchProgress struct {
+	UserID          uint       `gorm:"primaryKey;index:idx_watch_progress_recent,priority:1" json:"user_id"`
+	MovieID         uint       `gorm:"primaryKey;index" json:"movie_id"`
+	PositionSeconds int        `gorm:"not null" json:"position_seconds"`
+	DurationSeconds int        `gorm:"not null" json:"duration_seconds"`
+	Finished        bool       `gorm:"not null;default:false" json:"finished"`
+	FinishedAt      *time.Time `json:"finished_at"`
+	UpdatedAt       time.Time  `gorm:"autoUpdateTime:false;index:idx_watch_progress_recent,priority:2" json:"updated_at"`
+}
+
+// WatchHistory is one viewing session: heartbeats of a user in a movie on
+// one device without a long pause in between
+type WatchHistory struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	UserID          uint      `gorm:"index:idx_watch_history_recent,priority:1;not null" json:"user_id"`
+	MovieID         uint      `gorm:"index;not null" json:"movie_id"`
+	Device          string    `gorm:"type:varchar(100)" json:"device"`
+	StartedAt       time.Time `json:"started_at"`
+	LastWatchedAt   time.Time `gorm:"index:idx_watch_history_recent,priority:2" json:"last_watched_at"`
+	PositionSeconds int       `json:"position_seconds"`
+	Finished        bool      `gorm:"not null;default:false" json:"finished"`
+}
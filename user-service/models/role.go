@@ -0,0 +1,57 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"movie-rest/pkg/auth"
+)
+
+// Roles. Every user is a viewer; editor and admin are granted on top.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Permissions carried in access tokens and checked by the services
+const (
+	PermCatalogWrite = auth.PermCatalogWrite
+	PermRolesManage  = auth.PermRolesManage
+)
+
+// RolePermissions is what each role may do
+var RolePermissions = map[string][]string{
+	RoleViewer: {},
+	RoleEditor: {PermCatalogWrite},
+	RoleAdmin:  {PermCatalogWrite, PermRolesManage},
+}
+
+// UserRole grants a role to a user. Viewer is implied and never stored.
+type UserRole struct {
+	UserID    uint      `gorm:"primaryKey" json:"user_id"`
+	Role      string    `gorm:"primaryKey;type:varchar(20)" json:"role"`
+	GrantedBy *uint     `json:"granted_by"` // nil when granted by the bootstrap command
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Grants returns the roles (viewer included) and the permissions they add
+// up to, both sorted
+func Grants(stored []string) (roles, perms []string) {
+	roles = []string{RoleViewer}
+	seen := map[string]bool{}
+	for _, r := range stored {
+		if r != RoleViewer {
+			roles = append(roles, r)
+		}
+		for _, p := range RolePermissions[r] {
+			if !seen[p] {
+				seen[p] = true
+				perms = append(perms, p)
+			}
+		}
+	}
+	sort.Strings(roles)
+	sort.Strings(perms)
+	return roles, perms
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Session is one login of a user on a device. It lives as long as its
+// refresh tokens keep being rotated, until it expires or is revoked.
+type Session struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	Device     string     `gorm:"type:varchar(255)" json:"device"`
+	IP         string     `gorm:"type:varchar(45)" json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+
+	// the access token issued last, denylisted when the session is revoked
+	AccessJTI       string    `gorm:"type:varchar(64)" json:"-"`
+	AccessExpiresAt time.Time `json:"-"`
+}
+
+// RefreshToken is one token of a session's rotation chain. Only the sha256
+// of the token is stored. A token is used exactly once; presenting a used
+// token again means it leaked and revokes the whole session.
+type RefreshToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	SessionID uint      `gorm:"index;not null"`
+	TokenHash string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// RevokedToken denylists an access token by its jti until it would expire
+// anyway. All services consult this table through pkg/auth.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;type:varchar(64)"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
@@ -9,9 +9,10 @@ type User struct {
 	PasswordHash string `gorm:"type:text" json:"-"`
 	SubscriptionType     string     `gorm:"type:varchar(50);default:'none'" json:"subscription_type"`
     SubscriptionExpiredAt *time.Time `json:"subscription_expired_at"`
-	Watchlist []Movie `gorm:"many2many:watchlists;" json:"-"`
+	EmailVerifiedAt       *time.Time `json:"email_verified_at"` // nil until the address is verified
 }
 
-type Movie struct {
-    ID uint `gorm:"primaryKey"`
+// Verified reports whether the user verified their email address
+func (u *User) Verified() bool {
+	return u.EmailVerifiedAt != nil
 }
\ No newline at end of file
@@ -1,10 +0,0 @@
-package models
-
-import "time"
-
-// Watchlist akan menjadi join table antara User dan Movie (ID)
-type Watchlist struct {
-    UserID    uint      `gorm:"primaryKey"`
-    MovieID   uint      `gorm:"primaryKey"`
-    CreatedAt time.Time
-}
\ No newline at end of file
@@ -0,0 +1,114 @@
+package movieclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// movie-service returns at most this many movies per GET /movies
+const batchSize = 100
+
+// Client reads the public catalog of movie-service and reports views to it
+// with the shared INTERNAL_API_TOKEN
+type Client struct {
+	BaseURL       string
+	InternalToken string
+	HTTP          *http.Client
+}
+
+func NewFromEnv() *Client {
+	base := os.Getenv("MOVIE_SERVICE_URL")
+	if base == "" {
+		base = "http://localhost:8002"
+	}
+	return &Client{
+		BaseURL:       strings.TrimRight(base, "/"),
+		InternalToken: os.Getenv("INTERNAL_API_TOKEN"),
+		HTTP:          &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Movie is the part of a movie-service movie user-service cares about
+type Movie struct {
+	ID              uint    `json:"id"`
+	Title           string  `json:"title"`
+	PosterURL       string  `json:"poster_url"`
+	DurationMinutes int     `json:"duration_minutes"`
+	ReleaseYear     int     `json:"release_year"`
+	Rating          float64 `json:"rating"`
+	IsPremium       bool    `json:"is_premium"`
+}
+
+// Movies looks the given ids up in batches. Ids missing from the result do
+// not exist (anymore).
+func (c *Client) Movies(ctx context.Context, ids []uint) (map[uint]Movie, error) {
+	out := make(map[uint]Movie, len(ids))
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		parts := make([]string, 0, end-start)
+		for _, id := range ids[start:end] {
+			parts = append(parts, strconv.FormatUint(uint64(id), 10))
+		}
+		q := url.Values{}
+		q.Set("ids", strings.Join(parts, ","))
+		q.Set("limit", strconv.Itoa(batchSize))
+
+		var page struct {
+			Data []Movie `json:"data"`
+		}
+		if err := c.get(ctx, "/movies?"+q.Encode(), &page); err != nil {
+			return nil, err
+		}
+		for _, m := range page.Data {
+			out[m.ID] = m
+		}
+	}
+	return out, nil
+}
+
+// RecordView counts a view of movieID by userID. movie-service dedups it
+// with the views the user's player reports itself.
+func (c *Client) RecordView(ctx context.Context, movieID, userID uint) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/movies/%d/views", c.BaseURL, movieID), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Internal-Token", c.InternalToken)
+	req.Header.Set("X-User-ID", strconv.FormatUint(uint64(userID), 10))
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("movie-service record view %d: status %d", movieID, resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("movie-service %s: status %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
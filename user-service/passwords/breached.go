@@ -0,0 +1,56 @@
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PrefixLen is how many hex characters of the SHA-1 name a range file
+const PrefixLen = 5
+
+// BreachedList is a local copy of breached password hashes, laid out like
+// the Pwned Passwords range API: the uppercase SHA-1 of a password is split
+// after PrefixLen characters, and Dir/<PREFIX>.txt holds the "SUFFIX:COUNT"
+// lines of that range. A lookup reads one small file and needs no network.
+// cmd/import-breached builds the directory.
+type BreachedList struct {
+	Dir string
+}
+
+// Hash is the uppercase hex SHA-1 of password
+func Hash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// Contains reports whether password is on the list. A missing range file
+// means no breached password falls in that range.
+func (b *BreachedList) Contains(password string) (bool, error) {
+	h := Hash(password)
+	f, err := os.Open(filepath.Join(b.Dir, h[:PrefixLen]+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	suffix := h[PrefixLen:]
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.EqualFold(strings.TrimSpace(line), suffix) {
+			return true, nil
+		}
+	}
+	return false, sc.Err()
+}
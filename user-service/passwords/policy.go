@@ -0,0 +1,175 @@
+package passwords
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Violation is one broken rule, reported per request field
+type Violation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password breaks
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	return "password does not meet the policy"
+}
+
+// Policy is what a new password must satisfy
+type Policy struct {
+	MinLength  int     // in characters
+	MaxLength  int     // in bytes; bcrypt ignores everything after 72
+	MinEntropy float64 // in bits, see Entropy
+	Breached   *BreachedList
+}
+
+// BreachedDirFromEnv is PASSWORD_BREACHED_DIR, ./breached-passwords if unset
+func BreachedDirFromEnv() string {
+	if dir := os.Getenv("PASSWORD_BREACHED_DIR"); dir != "" {
+		return dir
+	}
+	return "./breached-passwords"
+}
+
+// NewPolicyFromEnv reads PASSWORD_MIN_LENGTH (8), PASSWORD_MIN_ENTROPY
+// (40 bits) and PASSWORD_BREACHED_DIR (./breached-passwords). A missing range
+// file reads as "not breached", so a missing or empty list directory would
+// silently let every breached password through; that is fatal instead.
+func NewPolicyFromEnv() *Policy {
+	p := &Policy{MinLength: 8, MaxLength: 72, MinEntropy: 40}
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && n > 0 {
+		p.MinLength = n
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("PASSWORD_MIN_ENTROPY"), 64); err == nil && v >= 0 {
+		p.MinEntropy = v
+	}
+	dir := BreachedDirFromEnv()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Fatalf("passwords: breached list %s: %v (build it with cmd/import-breached)", dir, err)
+	}
+	if len(entries) == 0 {
+		log.Fatalf("passwords: breached list %s is empty (build it with cmd/import-breached)", dir)
+	}
+	p.Breached = &BreachedList{Dir: dir}
+	return p
+}
+
+// Check returns a *PolicyError naming field when password breaks a rule.
+// email and name are the account's; a password containing them is
+// rejected. Other errors come from the breached list lookup.
+func (p *Policy) Check(field, password, email, name string) error {
+	var out []Violation
+	add := func(code, msg string) {
+		out = append(out, Violation{Field: field, Code: code, Message: msg})
+	}
+
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		add("too_short", fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if len(password) > p.MaxLength {
+		add("too_long", fmt.Sprintf("must be at most %d bytes", p.MaxLength))
+	}
+	if part := personalPart(password, email, name); part != "" {
+		add("contains_personal_info", "must not contain your "+part)
+	}
+	if password != "" && Entropy(password) < p.MinEntropy {
+		add("too_weak", "is too easy to guess; use a longer mix of words, numbers and symbols")
+	}
+	if p.Breached != nil && password != "" {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			add("breached", "appeared in a data breach; choose another one")
+		}
+	}
+
+	if len(out) > 0 {
+		return &PolicyError{Violations: out}
+	}
+	return nil
+}
+
+// personalPart names the piece of the account found in password, if any.
+// Pieces shorter than 3 characters are too common to reject.
+func personalPart(password, email, name string) string {
+	pw := strings.ToLower(password)
+	email = strings.ToLower(email)
+	local := email
+	if i := strings.IndexByte(email, '@'); i >= 0 {
+		local = email[:i]
+	}
+	for _, s := range []string{email, local} {
+		if len(s) >= 3 && strings.Contains(pw, s) {
+			return "email"
+		}
+	}
+	for _, s := range strings.Fields(strings.ToLower(name)) {
+		if utf8.RuneCountInString(s) >= 3 && strings.Contains(pw, s) {
+			return "name"
+		}
+	}
+	return ""
+}
+
+// Entropy estimates the strength of password in bits: the size of the
+// character classes it draws from, raised to its length. Repeated
+// characters and runs like "abc" or "321" add nothing to the length.
+func Entropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	effective := 0
+	var prev rune
+	for i, r := range []rune(password) {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+		if i == 0 || (r != prev && r != prev+1 && r != prev-1) {
+			effective++
+		}
+		prev = r
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if other {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(effective) * math.Log2(float64(pool))
+}
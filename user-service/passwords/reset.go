@@ -0,0 +1,156 @@
+package passwords
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"user-service/mail"
+	"user-service/models"
+	"user-service/sessions"
+	"user-service/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// Resetter emails reset tokens and sets new passwords with them
+type Resetter struct {
+	DB      *gorm.DB
+	Outbox  *mail.Outbox
+	Policy  *Policy
+	TTL     time.Duration
+	LinkURL string // the token is appended as ?token=
+}
+
+// NewResetterFromEnv reads PASSWORD_RESET_TTL (30m) and RESET_PASSWORD_URL,
+// the frontend page that posts the token to /password/reset
+func NewResetterFromEnv(db *gorm.DB, outbox *mail.Outbox, policy *Policy) *Resetter {
+	ttl := 30 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_TTL")); err == nil && d > 0 {
+		ttl = d
+	}
+	link := os.Getenv("RESET_PASSWORD_URL")
+	if link == "" {
+		link = "http://localhost:5173/reset-password"
+	}
+	return &Resetter{DB: db, Outbox: outbox, Policy: policy, TTL: ttl, LinkURL: link}
+}
+
+// Request emails a reset token when email belongs to a user. Unknown
+// addresses are not an error, callers must not tell them apart.
+func (r *Resetter) Request(ctx context.Context, email string) error {
+	var user models.User
+	err := r.DB.WithContext(ctx).Where("email = ?", strings.ToLower(strings.TrimSpace(email))).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return err
+	}
+	if err := r.DB.WithContext(ctx).Create(&models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(r.TTL),
+	}).Error; err != nil {
+		return err
+	}
+
+	link := r.LinkURL + "?token=" + url.QueryEscape(token)
+	r.Outbox.Enqueue(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nset a new password by opening this link:\n\n%s\n\n"+
+			"The link works once and expires in %s. If you did not ask for it, ignore this email; "+
+			"your password stays the same.\n", user.Name, link, r.TTL),
+	})
+	log.Printf("passwords: reset token issued for user %d", user.ID)
+	return nil
+}
+
+// Reset spends a token and sets the new password. Every other reset token
+// of the user stops working and every session ends, so whoever knew the
+// old password is signed out. Following the emailed link also proves the
+// address, so an unverified account becomes verified. A password the
+// policy rejects returns a *PolicyError and leaves the token unspent.
+func (r *Resetter) Reset(ctx context.Context, token, password string) (uint, error) {
+	now := time.Now()
+	var userID uint
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var pr models.PasswordReset
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(token), now).
+			First(&pr).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+		userID = pr.UserID
+
+		var user models.User
+		if err := tx.First(&user, pr.UserID).Error; err != nil {
+			return err
+		}
+		if err := r.Policy.Check("new_password", password, user.Email, user.Name); err != nil {
+			return err
+		}
+		hash, err := utils.HashPassword(password)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", pr.UserID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", pr.UserID).
+			Update("password_hash", hash).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ? AND email_verified_at IS NULL", pr.UserID).
+			Update("email_verified_at", now).Error; err != nil {
+			return err
+		}
+		return sessions.RevokeAllTx(tx, pr.UserID)
+	})
+	return userID, err
+}
+
+// Purge deletes spent and expired tokens
+func (r *Resetter) Purge(ctx context.Context) error {
+	return r.DB.WithContext(ctx).
+		Where("expires_at < ? OR used_at IS NOT NULL", time.Now()).
+		Delete(&models.PasswordReset{}).Error
+}
+
+// Start purges every hour until ctx is done
+func (r *Resetter) Start(ctx context.Context) {
+	go func() {
+		tick := time.NewTicker(time.Hour)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if err := r.Purge(ctx); err != nil {
+					log.Printf("passwords: purge failed: %v", err)
+				}
+			}
+		}
+	}()
+}
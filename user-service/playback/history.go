@@ -0,0 +1,164 @@
+package playback
+
+import (
+	"context"
+	"log"
+	"time"
+	"user-service/models"
+)
+
+// session is the in-memory state of one watch_histories row. id is 0 until
+// the row was inserted by a flush.
+type session struct {
+	id       uint
+	userID   uint
+	movieID  uint
+	device   string
+	started  time.Time
+	lastSeen time.Time
+	position int
+	finished bool
+	dirty    bool
+	// set when the session was dropped by ForgetHistory or Forget; a flush
+	// that was writing it meanwhile deletes the row again
+	forgotten bool
+}
+
+func (s *session) row() models.WatchHistory {
+	return models.WatchHistory{
+		ID:              s.id,
+		UserID:          s.userID,
+		MovieID:         s.movieID,
+		Device:          s.device,
+		StartedAt:       s.started,
+		LastWatchedAt:   s.lastSeen,
+		PositionSeconds: s.position,
+		Finished:        s.finished,
+	}
+}
+
+// touchSession folds a heartbeat into the current session of the user in
+// the movie. A new session starts after SessionGap of silence, on another
+// device, or when a finished movie is started again. The caller holds mu.
+func (t *Tracker) touchSession(p models.WatchProgress, device string) {
+	k := key{p.UserID, p.MovieID}
+	s := t.sessions[k]
+	if s == nil || s.device != device || p.UpdatedAt.Sub(s.lastSeen) > t.SessionGap || (s.finished && !p.Finished) {
+		if s != nil && s.dirty {
+			t.closed = append(t.closed, s)
+		}
+		s = &session{userID: p.UserID, movieID: p.MovieID, device: device, started: p.UpdatedAt}
+		t.sessions[k] = s
+	}
+	s.lastSeen = p.UpdatedAt
+	s.position = p.PositionSeconds
+	s.finished = s.finished || p.Finished
+	s.dirty = true
+}
+
+// flushSessions inserts new sessions and updates changed ones. Every new
+// session is also reported to movie-service as a view. The writes run
+// without mu, so each is checked afterwards against a concurrent forget.
+func (t *Tracker) flushSessions(ctx context.Context) error {
+	t.mu.Lock()
+	var dirty []*session
+	var rows []models.WatchHistory
+	for _, s := range t.closed {
+		dirty = append(dirty, s)
+		rows = append(rows, s.row())
+	}
+	t.closed = nil
+	for _, s := range t.sessions {
+		if s.dirty {
+			s.dirty = false
+			dirty = append(dirty, s)
+			rows = append(rows, s.row())
+		}
+	}
+	t.mu.Unlock()
+
+	db := t.DB.WithContext(ctx)
+	for i, s := range dirty {
+		row := rows[i]
+		var err error
+		if row.ID == 0 {
+			err = db.Create(&row).Error
+		} else {
+			err = db.Model(&models.WatchHistory{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+				"last_watched_at":  row.LastWatchedAt,
+				"position_seconds": row.PositionSeconds,
+				"finished":         row.Finished,
+			}).Error
+		}
+
+		t.mu.Lock()
+		if err != nil {
+			// this and the remaining sessions are retried with the next flush
+			for _, r := range dirty[i:] {
+				if r.forgotten {
+					continue
+				}
+				r.dirty = true
+				if t.sessions[key{r.userID, r.movieID}] != r {
+					t.closed = append(t.closed, r)
+				}
+			}
+			t.mu.Unlock()
+			return err
+		}
+		isNew := s.id == 0
+		s.id = row.ID
+		forgotten := s.forgotten
+		t.mu.Unlock()
+
+		if forgotten {
+			// the history was deleted while the row was written
+			if err := db.Delete(&models.WatchHistory{}, row.ID).Error; err != nil {
+				log.Printf("playback: deleting forgotten history entry %d failed: %v", row.ID, err)
+			}
+			continue
+		}
+		if isNew {
+			if err := t.Movies.RecordView(ctx, s.movieID, s.userID); err != nil {
+				log.Printf("playback: reporting view of movie %d failed: %v", s.movieID, err)
+			}
+		}
+	}
+
+	t.pruneSessions(time.Now())
+	return nil
+}
+
+// pruneSessions forgets persisted sessions that can no longer continue
+func (t *Tracker) pruneSessions(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, s := range t.sessions {
+		if !s.dirty && s.id != 0 && now.Sub(s.lastSeen) > t.SessionGap {
+			delete(t.sessions, k)
+		}
+	}
+}
+
+// ForgetHistory drops the in-memory sessions behind deleted history rows:
+// the row with entryID, or every row of the user when entryID is 0. Later
+// heartbeats then start a new session instead of updating a deleted row.
+func (t *Tracker) ForgetHistory(userID, entryID uint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, s := range t.sessions {
+		if k.UserID == userID && (entryID == 0 || s.id == entryID) {
+			s.forgotten = true
+			delete(t.sessions, k)
+		}
+	}
+	kept := t.closed[:0]
+	for _, s := range t.closed {
+		if s.userID == userID && (entryID == 0 || s.id == entryID) {
+			s.forgotten = true
+		} else {
+			kept = append(kept, s)
+		}
+	}
+	t.closed = kept
+}
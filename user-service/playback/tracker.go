@@ -0,0 +1,262 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"user-service/models"
+	"user-service/movieclient"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrMovieNotFound = errors.New("movie not found")
+
+// how long a movie duration fetched from movie-service is trusted
+const durationTTL = time.Hour
+
+type key struct {
+	UserID  uint
+	MovieID uint
+}
+
+type cachedDuration struct {
+	seconds int
+	fetched time.Time
+}
+
+// Tracker keeps the latest heartbeat per user and movie in memory and writes
+// them to watch_progress every FlushEvery, so a player ticking every few
+// seconds costs one upsert per flush instead of one per tick. Heartbeats are
+// also grouped into viewing sessions for the watch history.
+type Tracker struct {
+	DB         *gorm.DB
+	Movies     *movieclient.Client
+	FlushEvery time.Duration
+	// fraction of the duration after which a movie counts as finished
+	Threshold float64
+	// a pause longer than this starts a new history entry
+	SessionGap time.Duration
+
+	mu        sync.Mutex
+	pending   map[key]models.WatchProgress
+	durations map[uint]cachedDuration
+	sessions  map[key]*session
+	closed    []*session // replaced sessions with unflushed changes
+}
+
+func NewTracker(db *gorm.DB, movies *movieclient.Client) *Tracker {
+	flush := 15 * time.Second
+	if d, err := time.ParseDuration(os.Getenv("PLAYBACK_FLUSH_INTERVAL")); err == nil && d > 0 {
+		flush = d
+	}
+	threshold := 0.9
+	if v, err := strconv.ParseFloat(os.Getenv("PLAYBACK_FINISH_THRESHOLD"), 64); err == nil && v > 0 && v <= 1 {
+		threshold = v
+	}
+	gap := 30 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("PLAYBACK_SESSION_GAP")); err == nil && d > 0 {
+		gap = d
+	}
+	return &Tracker{
+		DB:         db,
+		Movies:     movies,
+		FlushEvery: flush,
+		Threshold:  threshold,
+		SessionGap: gap,
+		pending:    make(map[key]models.WatchProgress),
+		durations:  make(map[uint]cachedDuration),
+		sessions:   make(map[key]*session),
+	}
+}
+
+// duration returns the length of a movie in seconds
+func (t *Tracker) duration(ctx context.Context, movieID uint, now time.Time) (int, error) {
+	t.mu.Lock()
+	d, ok := t.durations[movieID]
+	t.mu.Unlock()
+	if ok && now.Sub(d.fetched) < durationTTL {
+		return d.seconds, nil
+	}
+
+	movies, err := t.Movies.Movies(ctx, []uint{movieID})
+	if err != nil {
+		return 0, err
+	}
+	m, ok := movies[movieID]
+	if !ok {
+		return 0, ErrMovieNotFound
+	}
+	seconds := m.DurationMinutes * 60
+	t.mu.Lock()
+	t.durations[movieID] = cachedDuration{seconds: seconds, fetched: now}
+	t.mu.Unlock()
+	return seconds, nil
+}
+
+// Heartbeat records the player position of a user in a movie on a device.
+// The latest heartbeat wins, so seeking back works. Passing Threshold of the
+// duration marks the movie finished; dropping below it again means a rewatch.
+func (t *Tracker) Heartbeat(ctx context.Context, userID, movieID uint, position int, device string, now time.Time) (models.WatchProgress, error) {
+	duration, err := t.duration(ctx, movieID, now)
+	if err != nil {
+		return models.WatchProgress{}, err
+	}
+	if duration > 0 && position > duration {
+		position = duration
+	}
+
+	p := models.WatchProgress{
+		UserID:          userID,
+		MovieID:         movieID,
+		PositionSeconds: position,
+		DurationSeconds: duration,
+		UpdatedAt:       now,
+	}
+	// a movie without a known duration is never finished automatically
+	if duration > 0 && float64(position) >= t.Threshold*float64(duration) {
+		p.Finished = true
+		p.FinishedAt = &now
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	k := key{userID, movieID}
+	if prev, ok := t.pending[k]; ok && prev.Finished && p.Finished {
+		// keep the moment it was first finished
+		p.FinishedAt = prev.FinishedAt
+	}
+	t.pending[k] = p
+	t.touchSession(p, device)
+	return p, nil
+}
+
+// Pending returns the unflushed progress of a user, newest state per movie
+func (t *Tracker) Pending(userID uint) []models.WatchProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []models.WatchProgress
+	for k, p := range t.pending {
+		if k.UserID == userID {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Forget drops unflushed progress and sessions of a deleted movie
+func (t *Tracker) Forget(movieID uint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k := range t.pending {
+		if k.MovieID == movieID {
+			delete(t.pending, k)
+		}
+	}
+	for k, s := range t.sessions {
+		if k.MovieID == movieID {
+			s.forgotten = true
+			delete(t.sessions, k)
+		}
+	}
+	kept := t.closed[:0]
+	for _, s := range t.closed {
+		if s.movieID == movieID {
+			s.forgotten = true
+		} else {
+			kept = append(kept, s)
+		}
+	}
+	t.closed = kept
+	delete(t.durations, movieID)
+}
+
+// Flush writes the pending progress and the changed history sessions
+func (t *Tracker) Flush(ctx context.Context) error {
+	if err := t.flushProgress(ctx); err != nil {
+		return err
+	}
+	return t.flushSessions(ctx)
+}
+
+// flushProgress upserts the pending progress in one statement. A row only
+// moves forward in time, so an older batch from another replica cannot
+// overwrite a newer one. On failure the batch is put back unless newer
+// heartbeats arrived meanwhile.
+func (t *Tracker) flushProgress(ctx context.Context) error {
+	t.mu.Lock()
+	batch := make([]models.WatchProgress, 0, len(t.pending))
+	for _, p := range t.pending {
+		batch = append(batch, p)
+	}
+	t.pending = make(map[key]models.WatchProgress)
+	t.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := t.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "movie_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"position_seconds": gorm.Expr("EXCLUDED.position_seconds"),
+			"duration_seconds": gorm.Expr("EXCLUDED.duration_seconds"),
+			"finished":         gorm.Expr("EXCLUDED.finished"),
+			"finished_at": gorm.Expr(`CASE WHEN EXCLUDED.finished AND watch_progresses.finished
+				THEN watch_progresses.finished_at ELSE EXCLUDED.finished_at END`),
+			"updated_at": gorm.Expr("EXCLUDED.updated_at"),
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "watch_progresses.updated_at < EXCLUDED.updated_at"},
+		}},
+	}).Create(&batch).Error
+	if err != nil {
+		t.restore(batch)
+		return err
+	}
+	return nil
+}
+
+func (t *Tracker) restore(batch []models.WatchProgress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range batch {
+		k := key{p.UserID, p.MovieID}
+		if _, newer := t.pending[k]; !newer {
+			t.pending[k] = p
+		}
+	}
+}
+
+// Start flushes every FlushEvery until ctx is done, then flushes once more
+// with a fresh context so buffered heartbeats survive a graceful shutdown.
+// The returned channel is closed after that final flush.
+func (t *Tracker) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tick := time.NewTicker(t.FlushEvery)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				if err := t.Flush(flushCtx); err != nil {
+					log.Printf("playback: final flush failed: %v", err)
+				}
+				cancel()
+				return
+			case <-tick.C:
+				if err := t.Flush(ctx); err != nil {
+					log.Printf("playback: flush failed: %v", err)
+				}
+			}
+		}
+	}()
+	return done
+}
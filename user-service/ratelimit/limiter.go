@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limiter allows Limit hits per key in a fixed Window. Counts live in
+// memory, so every replica of user-service limits on its own.
+type Limiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu      sync.Mutex
+	windows map[string]*window
+}
+
+type window struct {
+	start time.Time
+	hits  int
+}
+
+func New(limit int, w time.Duration) *Limiter {
+	return &Limiter{Limit: limit, Window: w, windows: map[string]*window{}}
+}
+
+// Allow counts a hit for key. When the key is over its limit it returns
+// false and how long until the window resets.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.Window {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+	w.hits++
+	if w.hits > l.Limit {
+		return false, w.start.Add(l.Window).Sub(now)
+	}
+	return true, 0
+}
+
+// Start drops finished windows every Window until ctx is done
+func (l *Limiter) Start(ctx context.Context) {
+	go func() {
+		tick := time.NewTicker(l.Window)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-tick.C:
+				l.mu.Lock()
+				for k, w := range l.windows {
+					if now.Sub(w.start) >= l.Window {
+						delete(l.windows, k)
+					}
+				}
+				l.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Reject answers 429 with a Retry-After header
+func Reject(c *gin.Context, retry time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests, try again later"})
+}
+
+// PerIP limits a route by client IP
+func PerIP(l *Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ok, retry := l.Allow(c.FullPath() + "|" + c.ClientIP()); !ok {
+			Reject(c, retry)
+			return
+		}
+		c.Next()
+	}
+}
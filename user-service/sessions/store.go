@@ -0,0 +1,370 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"user-service/keys"
+	"user-service/models"
+	"user-service/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"movie-rest/pkg/auth"
+)
+
+var (
+	ErrInvalidRefresh  = errors.New("invalid refresh token")
+	ErrRefreshReused   = errors.New("refresh token reused, session revoked")
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// Store issues access and refresh tokens for login sessions. Access tokens
+// are short-lived JWTs; refresh tokens are random, stored hashed, and
+// rotated on every use.
+type Store struct {
+	DB         *gorm.DB
+	Keys       *keys.Keyring
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// roles whose permissions are withheld until the user enables 2FA
+	MFARequiredRoles []string
+}
+
+func NewStore(db *gorm.DB, kr *keys.Keyring) *Store {
+	access := 15 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL")); err == nil && d > 0 {
+		access = d
+	}
+	refresh := 30 * 24 * time.Hour
+	if d, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL")); err == nil && d > 0 {
+		refresh = d
+	}
+	// MFA_REQUIRED_ROLES is a comma separated list, "admin" by default;
+	// set it to "-" to force no role
+	required := []string{models.RoleAdmin}
+	if v := os.Getenv("MFA_REQUIRED_ROLES"); v != "" {
+		required = nil
+		for _, r := range strings.Split(v, ",") {
+			if r = strings.TrimSpace(r); r != "" && r != "-" {
+				required = append(required, r)
+			}
+		}
+	}
+	return &Store{DB: db, Keys: kr, AccessTTL: access, RefreshTTL: refresh, MFARequiredRoles: required}
+}
+
+// Tokens is what a client gets on login and on every refresh
+type Tokens struct {
+	AccessToken  string    `json:"token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+	SessionID    uint      `json:"session_id"`
+}
+
+// Create starts a session for a user who just logged in
+func (s *Store) Create(ctx context.Context, user models.User, device, ip string) (Tokens, error) {
+	now := time.Now()
+	var out Tokens
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		sess := models.Session{
+			UserID:     user.ID,
+			Device:     device,
+			IP:         ip,
+			LastUsedAt: now,
+			ExpiresAt:  now.Add(s.RefreshTTL),
+		}
+		if err := tx.Create(&sess).Error; err != nil {
+			return err
+		}
+		var err error
+		out, err = s.issue(tx, &sess, user, now)
+		return err
+	})
+	return out, err
+}
+
+// Refresh trades a refresh token for a new pair. The old refresh token and
+// the access token issued with it stop working. Presenting a refresh token
+// that was already used revokes the session, since either the client or a
+// thief holds a copy.
+func (s *Store) Refresh(ctx context.Context, raw, device, ip string) (Tokens, error) {
+	now := time.Now()
+	var out Tokens
+	var reused uint // the session revoked for reuse
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rt models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(raw)).First(&rt).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefresh
+		}
+		if err != nil {
+			return err
+		}
+
+		var sess models.Session
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sess, rt.SessionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefresh
+			}
+			return err
+		}
+		if sess.RevokedAt != nil || !now.Before(sess.ExpiresAt) || !now.Before(rt.ExpiresAt) {
+			return ErrInvalidRefresh
+		}
+		if rt.UsedAt != nil {
+			// commit the revocation, the error is returned afterwards
+			reused = sess.ID
+			return revoke(tx, &sess, now)
+		}
+
+		if err := tx.Model(&rt).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := deny(tx, sess.AccessJTI, sess.AccessExpiresAt, now); err != nil {
+			return err
+		}
+		var user models.User
+		if err := tx.First(&user, sess.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefresh
+			}
+			return err
+		}
+		sess.Device, sess.IP = device, ip
+		out, err = s.issue(tx, &sess, user, now)
+		return err
+	})
+	if err == nil && reused != 0 {
+		log.Printf("sessions: refresh token reused, revoked session %d", reused)
+		return Tokens{}, ErrRefreshReused
+	}
+	return out, err
+}
+
+// issue creates the next refresh token of a session and signs an access
+// token for it, carrying the user's current roles
+func (s *Store) issue(tx *gorm.DB, sess *models.Session, user models.User, now time.Time) (Tokens, error) {
+	refresh, err := utils.RandomToken(32)
+	if err != nil {
+		return Tokens{}, err
+	}
+	if err := tx.Create(&models.RefreshToken{
+		SessionID: sess.ID,
+		TokenHash: utils.HashToken(refresh),
+		ExpiresAt: sess.ExpiresAt,
+	}).Error; err != nil {
+		return Tokens{}, err
+	}
+
+	stored, _, err := s.grantedRoles(tx, user.ID)
+	if err != nil {
+		return Tokens{}, err
+	}
+	roles, perms := models.Grants(stored)
+
+	jti, err := utils.RandomToken(16)
+	if err != nil {
+		return Tokens{}, err
+	}
+	exp := now.Add(s.AccessTTL)
+	access, err := utils.GenerateToken(s.Keys, auth.Claims{
+		UserID:      user.ID,
+		Email:       user.Email,
+		SessionID:   sess.ID,
+		Roles:       roles,
+		Permissions: perms,
+	}, jti, exp)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if err := tx.Model(sess).Updates(map[string]interface{}{
+		"device":            sess.Device,
+		"ip":                sess.IP,
+		"last_used_at":      now,
+		"access_jti":        jti,
+		"access_expires_at": exp,
+	}).Error; err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{AccessToken: access, ExpiresAt: exp, RefreshToken: refresh, SessionID: sess.ID}, nil
+}
+
+// grantedRoles splits the stored roles of a user into those that go into
+// tokens and those withheld because the user has not enabled 2FA
+func (s *Store) grantedRoles(tx *gorm.DB, userID uint) (granted, withheld []string, err error) {
+	var stored []string
+	if err := tx.Model(&models.UserRole{}).Where("user_id = ?", userID).Pluck("role", &stored).Error; err != nil {
+		return nil, nil, err
+	}
+	var mfaEnabled *bool // looked up once, when needed
+	for _, r := range stored {
+		if !contains(s.MFARequiredRoles, r) {
+			granted = append(granted, r)
+			continue
+		}
+		if mfaEnabled == nil {
+			var n int64
+			if err := tx.Model(&models.UserMFA{}).
+				Where("user_id = ? AND confirmed_at IS NOT NULL", userID).Count(&n).Error; err != nil {
+				return nil, nil, err
+			}
+			enabled := n > 0
+			mfaEnabled = &enabled
+		}
+		if *mfaEnabled {
+			granted = append(granted, r)
+		} else {
+			withheld = append(withheld, r)
+		}
+	}
+	return granted, withheld, nil
+}
+
+// WithheldRoles lists the roles of a user that wait for 2FA to be enabled
+func (s *Store) WithheldRoles(ctx context.Context, userID uint) ([]string, error) {
+	_, withheld, err := s.grantedRoles(s.DB.WithContext(ctx), userID)
+	return withheld, err
+}
+
+// RoleRequiresMFA reports whether role is only granted with 2FA enabled
+func (s *Store) RoleRequiresMFA(role string) bool {
+	return contains(s.MFARequiredRoles, role)
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Active lists the sessions of a user that can still be refreshed, most
+// recently used first
+func (s *Store) Active(ctx context.Context, userID uint) ([]models.Session, error) {
+	var out []models.Session
+	err := s.DB.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").Find(&out).Error
+	return out, err
+}
+
+// Revoke ends a session of a user: its refresh tokens are deleted and its
+// current access token is denylisted
+func (s *Store) Revoke(ctx context.Context, userID, sessionID uint) error {
+	now := time.Now()
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var sess models.Session
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).First(&sess).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return revoke(tx, &sess, now)
+	})
+}
+
+// RevokeAll ends every session of a user
+func (s *Store) RevokeAll(ctx context.Context, userID uint) error {
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return RevokeAllTx(tx, userID)
+	})
+}
+
+// RevokeAllTx is RevokeAll inside the caller's transaction, so the sessions
+// end together with the change that requires it
+func RevokeAllTx(tx *gorm.DB, userID uint) error {
+	now := time.Now()
+	var list []models.Session
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).Find(&list).Error; err != nil {
+		return err
+	}
+	for i := range list {
+		if err := revoke(tx, &list[i], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpireAccess denylists the current access tokens of every session of a
+// user. The sessions stay; clients refresh and get tokens with the user's
+// current roles.
+func (s *Store) ExpireAccess(ctx context.Context, userID uint) error {
+	now := time.Now()
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var list []models.Session
+		if err := tx.Where("user_id = ? AND revoked_at IS NULL AND access_expires_at > ?", userID, now).
+			Find(&list).Error; err != nil {
+			return err
+		}
+		for _, sess := range list {
+			if err := deny(tx, sess.AccessJTI, sess.AccessExpiresAt, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func revoke(tx *gorm.DB, sess *models.Session, now time.Time) error {
+	if err := tx.Model(sess).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("session_id = ?", sess.ID).Delete(&models.RefreshToken{}).Error; err != nil {
+		return err
+	}
+	return deny(tx, sess.AccessJTI, sess.AccessExpiresAt, now)
+}
+
+// deny puts an access token on the denylist, unless it expired already
+func deny(tx *gorm.DB, jti string, expiresAt, now time.Time) error {
+	if jti == "" || !now.Before(expiresAt) {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+// Purge deletes expired denylist entries and sessions that ended
+func (s *Store) Purge(ctx context.Context) error {
+	db := s.DB.WithContext(ctx)
+	now := time.Now()
+	if err := db.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	ended := db.Model(&models.Session{}).Select("id").Where("expires_at < ? OR revoked_at IS NOT NULL", now)
+	if err := db.Where("session_id IN (?)", ended).Delete(&models.RefreshToken{}).Error; err != nil {
+		return err
+	}
+	return db.Where("expires_at < ? OR revoked_at < ?", now, now.Add(-s.AccessTTL)).Delete(&models.Session{}).Error
+}
+
+// Start purges every hour until ctx is done
+func (s *Store) Start(ctx context.Context) {
+	go func() {
+		tick := time.NewTicker(time.Hour)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if err := s.Purge(ctx); err != nil {
+					log.Printf("sessions: purge failed: %v", err)
+				}
+			}
+		}
+	}()
+}
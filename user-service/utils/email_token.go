@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"movie-rest/pkg/auth"
+)
+
+// Audiences of emailed tokens, so a link made for one purpose cannot be
+// used for another. No access token verifier accepts them.
+const (
+	AudienceVerifyEmail = "verify-email"
+)
+
+var ErrInvalidEmailToken = errors.New("invalid or expired token")
+
+type emailClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// EmailTokens signs the links emailed to users. They are HMAC-signed with
+// EMAIL_TOKEN_SECRET rather than with the rotating access token keys, whose
+// grace period is shorter than a link stays valid.
+type EmailTokens struct {
+	Secret []byte
+	TTL    time.Duration
+}
+
+// NewEmailTokensFromEnv reads EMAIL_TOKEN_SECRET and EMAIL_VERIFY_TTL (48h)
+func NewEmailTokensFromEnv() (*EmailTokens, error) {
+	secret := os.Getenv("EMAIL_TOKEN_SECRET")
+	if len(secret) < 32 {
+		return nil, errors.New("EMAIL_TOKEN_SECRET must be at least 32 characters")
+	}
+	ttl := 48 * time.Hour
+	if d, err := time.ParseDuration(os.Getenv("EMAIL_VERIFY_TTL")); err == nil && d > 0 {
+		ttl = d
+	}
+	return &EmailTokens{Secret: []byte(secret), TTL: ttl}, nil
+}
+
+// Sign returns a token verifying email for the user. It names the address,
+// so it stops working once the user changes their email.
+func (t *EmailTokens) Sign(userID uint, email string) (string, error) {
+	return t.SignFor(AudienceVerifyEmail, userID, email, t.TTL)
+}
+
+// Parse returns the user and the address a verification token verifies
+func (t *EmailTokens) Parse(token string) (uint, string, error) {
+	return t.ParseFor(AudienceVerifyEmail, token)
+}
+
+// SignFor returns a token for aud, valid for ttl
+func (t *EmailTokens) SignFor(aud string, userID uint, email string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := emailClaims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			Issuer:    auth.Issuer(),
+			Audience:  jwt.ClaimStrings{aud},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.Secret)
+}
+
+// ParseFor returns the user and the address of a token made for aud
+func (t *EmailTokens) ParseFor(aud, token string) (uint, string, error) {
+	claims := &emailClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return t.Secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(auth.Issuer()),
+		jwt.WithAudience(aud),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return 0, "", ErrInvalidEmailToken
+	}
+	id, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil || id == 0 || claims.Email == "" {
+		return 0, "", ErrInvalidEmailToken
+	}
+	return uint(id), claims.Email, nil
+}
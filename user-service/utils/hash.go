@@ -1,8 +1,20 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+    "errors"
 
+    "golang.org/x/crypto/bcrypt"
+)
+
+var ErrEmptyPassword = errors.New("password is empty")
+
+// HashPassword hashes with bcrypt. Whether the password is good enough is
+// for passwords.Policy to decide; this only refuses what bcrypt cannot hash
+// faithfully.
 func HashPassword(password string) (string, error) {
+    if password == "" {
+        return "", ErrEmptyPassword
+    }
     bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
     return string(bytes), err
 }
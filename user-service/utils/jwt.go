@@ -1,20 +1,22 @@
 package utils
 
 import (
-	"os"
 	"time"
+	"user-service/keys"
 
 	"github.com/golang-jwt/jwt/v5"
+	"movie-rest/pkg/auth"
 )
 
-func GenerateToken(userID int, email string) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"email":   email,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
-	})
-
-	return token.SignedString([]byte(secret))
+// GenerateToken signs a short-lived access token. The caller fills in the
+// user, session and grants; jti names the token on the denylist.
+func GenerateToken(kr *keys.Keyring, claims auth.Claims, jti string, expiresAt time.Time) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		Issuer:    auth.Issuer(),
+		Audience:  jwt.ClaimStrings{auth.Audience()},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	return kr.Sign(claims)
 }
@@ -0,0 +1,87 @@
+package verification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+	"user-service/mail"
+	"user-service/models"
+	"user-service/utils"
+
+	"gorm.io/gorm"
+)
+
+var ErrAlreadyVerified = errors.New("email already verified")
+
+// Service sends verification links and activates accounts when a link is
+// followed
+type Service struct {
+	DB      *gorm.DB
+	Tokens  *utils.EmailTokens
+	Outbox  *mail.Outbox
+	LinkURL string // the token is appended as ?token=
+}
+
+// NewFromEnv builds the links on VERIFY_EMAIL_URL, which defaults to
+// user-service's own GET /verify-email
+func NewFromEnv(db *gorm.DB, outbox *mail.Outbox) (*Service, error) {
+	tokens, err := utils.NewEmailTokensFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	link := os.Getenv("VERIFY_EMAIL_URL")
+	if link == "" {
+		link = "http://localhost:8001/verify-email"
+	}
+	return &Service{DB: db, Tokens: tokens, Outbox: outbox, LinkURL: link}, nil
+}
+
+// Send queues a verification email for the user's current address
+func (s *Service) Send(user models.User) error {
+	if user.Verified() {
+		return ErrAlreadyVerified
+	}
+	token, err := s.Tokens.Sign(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+	link := s.LinkURL + "?token=" + url.QueryEscape(token)
+	s.Outbox.Enqueue(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nconfirm your email address by opening this link:\n\n%s\n\n"+
+			"The link expires in %s. If you did not sign up, ignore this email.\n",
+			user.Name, link, s.Tokens.TTL),
+	})
+	return nil
+}
+
+// Confirm verifies the address a token was issued for. Following a link
+// twice is fine; a link for an address the user no longer has is not.
+func (s *Service) Confirm(ctx context.Context, token string) (models.User, error) {
+	userID, email, err := s.Tokens.Parse(token)
+	if err != nil {
+		return models.User{}, err
+	}
+	var user models.User
+	if err := s.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.User{}, utils.ErrInvalidEmailToken
+		}
+		return models.User{}, err
+	}
+	if user.Email != email {
+		return models.User{}, utils.ErrInvalidEmailToken
+	}
+	if user.Verified() {
+		return user, nil
+	}
+	now := time.Now()
+	if err := s.DB.WithContext(ctx).Model(&user).Update("email_verified_at", now).Error; err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}